@@ -0,0 +1,144 @@
+package modbuslabs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs/config"
+)
+
+// accessState is the state of an access range of the register map.
+type accessState struct {
+	written    map[uint16]bool // write_once addresses already written
+	progress   int             // values of the unlock sequence written so far
+	unlockedAt time.Time       // zero while locked
+}
+
+// accessTables maps the data tables of access ranges to the tables of
+// tableAccess.
+var accessTables = map[string]string{
+	"holding_registers": "holding register",
+	"coils":             "coil",
+}
+
+// locked reports whether the range a with state st is locked at now.
+func (st *accessState) locked(a config.Access, now time.Time) bool {
+	if len(a.UnlockSequence) == 0 {
+		return false
+	}
+	if st.unlockedAt.IsZero() {
+		return true
+	}
+	return a.UnlockTimeout > 0 && now.Sub(st.unlockedAt) >= a.UnlockTimeout
+}
+
+// accessDenied reports why the access ranges of the register map deny pdu
+// received at now and the exception to answer with.
+func (s *Slave) accessDenied(pdu PDU, now time.Time) (string, uint8, bool) {
+	for _, ta := range s.tableAccesses(pdu) {
+		for i, a := range s.registerMap.Access {
+			if accessTables[a.DataTable()] != ta.table {
+				continue
+			}
+			st := &s.access[i]
+			for j := range uint32(ta.quantity) {
+				addr := uint32(ta.addr) + j
+				if addr > 0xFFFF || !a.Contains(uint16(addr)) {
+					continue
+				}
+				var reason string
+				switch {
+				case !ta.write && a.Mode == "write_only":
+					reason = "is write-only"
+				case ta.write && a.Mode == "read_only":
+					reason = "is read-only"
+				case ta.write && a.Mode == "write_once" && st.written[uint16(addr)]:
+					reason = "was already written once"
+				case ta.write && st.locked(a, now):
+					reason = "is locked"
+				default:
+					continue
+				}
+				exception := a.Exception
+				if exception == 0 {
+					exception = ExIllegalDataAddress
+				}
+				return fmt.Sprintf("%s 0x%04X %s", ta.table, addr, reason), exception, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// recordWrites marks the write_once addresses written by pdu and advances
+// the unlock sequences by the values written to unlock registers. It returns
+// the ranges unlocked by pdu.
+func (s *Slave) recordWrites(pdu PDU, now time.Time) []config.Access {
+	for _, ta := range s.tableAccesses(pdu) {
+		if !ta.write {
+			continue
+		}
+		for i, a := range s.registerMap.Access {
+			if a.Mode != "write_once" || accessTables[a.DataTable()] != ta.table {
+				continue
+			}
+			for j := range uint32(ta.quantity) {
+				if addr := uint32(ta.addr) + j; addr <= 0xFFFF && a.Contains(uint16(addr)) {
+					s.access[i].written[uint16(addr)] = true
+				}
+			}
+		}
+	}
+
+	var unlocked []config.Access
+	addr, quantity, ok := WriteRange(pdu)
+	if !ok || pdu.FunctionCode == FC5WriteSingleCoil || pdu.FunctionCode == FC15WriteMultipleCoils {
+		return nil
+	}
+	values := writeValues(pdu)
+	for k := range min(quantity, uint16(len(values))) {
+		for i, a := range s.registerMap.Access {
+			if len(a.UnlockSequence) == 0 || a.UnlockRegister != addr+k {
+				continue
+			}
+			st := &s.access[i]
+			if values[k] != a.UnlockSequence[st.progress] {
+				// A wrong value restarts the sequence, it may be its first value
+				st.progress = 0
+				if values[k] != a.UnlockSequence[0] {
+					continue
+				}
+			}
+			st.progress++
+			if st.progress == len(a.UnlockSequence) {
+				st.progress = 0
+				st.unlockedAt = now
+				unlocked = append(unlocked, a)
+			}
+		}
+	}
+	return unlocked
+}
+
+// accessStatus describes the access ranges of the register map.
+func (s *Slave) accessStatus() string {
+	names := map[string]string{"holding_registers": "Holding registers", "coils": "Coils"}
+	var status string
+	now := time.Now()
+	for i, a := range s.registerMap.Access {
+		attributes := []string{"read_write"}
+		if a.Mode != "" {
+			attributes[0] = a.Mode
+		}
+		if len(a.UnlockSequence) > 0 {
+			if s.access[i].locked(a, now) {
+				attributes = append(attributes, fmt.Sprintf("locked, unlock via 0x%04X", a.UnlockRegister))
+			} else {
+				attributes = append(attributes, "unlocked")
+			}
+		}
+		status += fmt.Sprintf("\n    - %s 0x%04X-0x%04X: %s", names[a.DataTable()], a.Start, uint32(a.Start)+uint32(a.Count)-1, strings.Join(attributes, ", "))
+	}
+	return status
+}
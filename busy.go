@@ -0,0 +1,115 @@
+package modbuslabs
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/encoding"
+	"github.com/rwirdemann/modbuslabs/rules"
+)
+
+// busy reports why the slave answers pdu received at now with exception 06:
+// it is within a busy window covering the function code, or it is polled
+// faster than its minimum poll interval. Every request restarts the poll
+// interval, so a master polling too fast keeps getting busy responses.
+func (s *Slave) busy(pdu PDU, now time.Time) (string, bool) {
+	last := s.lastRequest
+	s.lastRequest = now
+
+	if now.Before(s.busyUntil) && (len(s.busyFCs) == 0 || slices.Contains(s.busyFCs, pdu.FunctionCode)) {
+		return fmt.Sprintf("busy for another %s", s.busyUntil.Sub(now).Round(time.Millisecond)), true
+	}
+	if s.minPollInterval > 0 && !last.IsZero() && now.Sub(last) < s.minPollInterval {
+		return fmt.Sprintf("polled %s after the previous request, minimum interval is %s", now.Sub(last).Round(time.Millisecond), s.minPollInterval), true
+	}
+	return "", false
+}
+
+// setBusy starts a busy window of duration d from now, during which the
+// function codes fcs, or all if fcs is empty, are answered busy. A duration
+// of 0 ends the busy window.
+func (s *Slave) setBusy(now time.Time, d time.Duration, fcs []uint8) {
+	s.busyUntil = now.Add(d)
+	s.busyFCs = fcs
+}
+
+// triggerBusy starts the busy window of the first busy rule triggered by
+// pdu and returns the rule.
+func (s *Slave) triggerBusy(pdu PDU, now time.Time) (config.Rule, bool) {
+	if addr, quantity, ok := WriteRange(pdu); ok {
+		values := writeValues(pdu)
+		for i := range min(quantity, uint16(len(values))) {
+			if rule, ok := s.ruleEngine.Busy(addr+i, values[i], rules.TriggerOnWrite); ok {
+				s.setBusy(now, rule.Duration, rule.FunctionCodes)
+				return rule, true
+			}
+		}
+	}
+	if addr, quantity, ok := ReadRange(pdu); ok {
+		for i := range quantity {
+			if rule, ok := s.ruleEngine.Busy(addr+i, s.registers[addr+i], rules.TriggerOnRead); ok {
+				s.setBusy(now, rule.Duration, rule.FunctionCodes)
+				return rule, true
+			}
+		}
+	}
+	return config.Rule{}, false
+}
+
+// busyStatus describes the busy window and the minimum poll interval.
+func (s *Slave) busyStatus() string {
+	var status string
+	if now := time.Now(); now.Before(s.busyUntil) {
+		fcs := "all FCs"
+		if len(s.busyFCs) > 0 {
+			fcs = fmt.Sprintf("FCs %v", s.busyFCs)
+		}
+		status += fmt.Sprintf("\n    Busy until %s (%s)", s.busyUntil.Format(time.TimeOnly), fcs)
+	}
+	if s.minPollInterval > 0 {
+		status += fmt.Sprintf("\n    Min poll interval: %s", s.minPollInterval)
+	}
+	return status
+}
+
+// writeValues returns the register values written by pdu. Coils written by
+// FC15 are returned like FC5 values, 0xFF00 for ON and 0x0000 for OFF.
+func writeValues(pdu PDU) []uint16 {
+	var data []byte
+	switch pdu.FunctionCode {
+	case FC5WriteSingleCoil, FC6WriteSingleRegister:
+		if len(pdu.Payload) >= 4 {
+			data = pdu.Payload[2:4]
+		}
+	case FC15WriteMultipleCoils:
+		if len(pdu.Payload) < 5 {
+			return nil
+		}
+		quantity := encoding.BytesToUint16(pdu.Payload[2:4])
+		coils := pdu.Payload[5:]
+		var values []uint16
+		for i := range min(int(quantity), 8*len(coils)) {
+			var value uint16
+			if coils[i/8]&(1<<(i%8)) != 0 {
+				value = 0xFF00
+			}
+			values = append(values, value)
+		}
+		return values
+	case FC16WriteMultipleRegisters:
+		if len(pdu.Payload) >= 5 {
+			data = pdu.Payload[5:]
+		}
+	case FC17ReadWriteMultipleRegisters:
+		if len(pdu.Payload) >= 9 {
+			data = pdu.Payload[9:]
+		}
+	}
+	var values []uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		values = append(values, encoding.BytesToUint16(data[i:i+2]))
+	}
+	return values
+}
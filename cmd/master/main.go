@@ -25,6 +25,16 @@ func connect(transport, url string, slaveID int) (bmodbus.Client, func()) {
 			log.Fatal(err)
 		}
 		return bmodbus.NewClient(h), func() { h.Close() }
+	case "unix":
+		// The TCP client handler only serves as MBAP packager here, the
+		// frames are sent over the Unix domain socket at url.
+		p := bmodbus.NewTCPClientHandler(url)
+		p.SlaveId = uint8(slaveID)
+		t, err := dialUnix(url, 1*time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return bmodbus.NewClient2(p, t), func() { t.Close() }
 	case "rtu":
 		h := bmodbus.NewRTUClientHandler("/tmp/ttyV1")
 		h.Timeout = 5 * time.Second
@@ -57,7 +67,8 @@ Subcommands:
 Run 'master <subcommand> -h' for subcommand-specific flags.
 
 Example:
-  master fc16 -addr 0x0100 -value 65536 -quantity 2 -transport tcp -url localhost:502 -slave 101`)
+  master fc16 -addr 0x0100 -value 65536 -quantity 2 -transport tcp -url localhost:502 -slave 101
+  master fc4 -addr 0x9000 -transport unix -url /tmp/slavesim.sock -slave 101`)
 }
 
 func main() {
@@ -74,7 +85,7 @@ func main() {
 	case "fc2":
 		cmd := flag.NewFlagSet("fc2", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		quantity := cmd.Int("quantity", 1, "number of discrete inputs to read")
@@ -104,7 +115,7 @@ func main() {
 	case "fc4":
 		cmd := flag.NewFlagSet("fc4", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		quantity := cmd.Int("quantity", 1, "number of registers to read")
@@ -154,7 +165,7 @@ func main() {
 	case "fc5":
 		cmd := flag.NewFlagSet("fc5", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		value := cmd.String("value", "", "true or false")
@@ -182,7 +193,7 @@ func main() {
 	case "fc6":
 		cmd := flag.NewFlagSet("fc6", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		value := cmd.String("value", "", "uint16 value")
@@ -210,7 +221,7 @@ func main() {
 	case "fc16":
 		cmd := flag.NewFlagSet("fc16", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		value := cmd.String("value", "", "float32, uint16, or integer value")
@@ -284,7 +295,7 @@ func main() {
 	case "fc17":
 		cmd := flag.NewFlagSet("fc17", flag.ExitOnError)
 		addr := cmd.String("addr", "0x000", "0x0000 to 0x270F")
-		transport := cmd.String("transport", "tcp", "tcp|rtu")
+		transport := cmd.String("transport", "tcp", "tcp|unix|rtu")
 		slaveID := cmd.Int("slave", 101, "slave id")
 		url := cmd.String("url", "localhost:502", "url to connect")
 		quantity := cmd.Int("quantity", 1, "number of registers to read")
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	mbapHeaderLength = 7
+	maxFrameLength   = 260
+)
+
+// unixTransporter sends MBAP framed requests over a Unix domain socket. It
+// complements the MBAP packager of goburrow's TCP client handler, which only
+// dials TCP addresses itself.
+type unixTransporter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func dialUnix(path string, timeout time.Duration) (*unixTransporter, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &unixTransporter{conn: conn, timeout: timeout}, nil
+}
+
+// Send writes aduRequest and reads the complete response frame.
+func (t *unixTransporter) Send(aduRequest []byte) ([]byte, error) {
+	if err := t.conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := t.conn.Write(aduRequest); err != nil {
+		return nil, err
+	}
+
+	var data [maxFrameLength]byte
+	if _, err := io.ReadFull(t.conn, data[:mbapHeaderLength]); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	if length <= 0 || length > maxFrameLength-mbapHeaderLength+1 {
+		return nil, fmt.Errorf("invalid length %d in response header", length)
+	}
+	end := mbapHeaderLength + length - 1
+	if _, err := io.ReadFull(t.conn, data[mbapHeaderLength:end]); err != nil {
+		return nil, err
+	}
+	return data[:end], nil
+}
+
+func (t *unixTransporter) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/session"
+	"github.com/rwirdemann/modbuslabs/upstream"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	sessionFile := flag.String("session", "", "session file recorded with slavesim -record")
+	url := flag.String("url", "tcp://localhost:502", "target to replay against: tcp://host:port or rtu://<serial device>")
+	transport := flag.String("transport", "", "only replay requests recorded on this transport address")
+	fast := flag.Bool("fast", false, "send requests as fast as possible instead of with the recorded timing")
+	timeout := flag.Duration("timeout", upstream.DefaultTimeout, "response timeout")
+	debug := flag.Bool("debug", false, "set log level to debug")
+	flag.Parse()
+
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if *sessionFile == "" {
+		flag.Usage()
+		return 1
+	}
+
+	exchanges, err := session.Read(*sessionFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	client, err := upstream.NewClient(*url, upstream.Options{Timeout: *timeout})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	var replayed, differences int
+	started := time.Now()
+	for i, e := range exchanges {
+		if *transport != "" && e.Transport != *transport {
+			continue
+		}
+		if !*fast {
+			time.Sleep(time.Until(started.Add(e.Offset)))
+		}
+		replayed++
+
+		res, err := client.Send(modbuslabs.PDU{UnitId: e.Request.UnitID, FunctionCode: e.Request.FunctionCode, Payload: e.Request.Payload})
+		if diff := compare(e.Response, res, err); diff != "" {
+			differences++
+			fmt.Printf("#%d %s: %s\n", i+1, e.Request, diff)
+		}
+	}
+
+	fmt.Printf("%d requests replayed, %d responses differ\n", replayed, differences)
+	if differences > 0 {
+		return 2
+	}
+	return 0
+}
+
+// compare returns a description of the difference between the recorded
+// response and the response received during the replay, or "" if both match.
+func compare(recorded *session.Frame, res *modbuslabs.PDU, err error) string {
+	switch {
+	case recorded == nil && err != nil:
+		return ""
+	case recorded == nil:
+		return fmt.Sprintf("expected no response, got FC=%d Payload=% X", res.FunctionCode, res.Payload)
+	case err != nil:
+		return fmt.Sprintf("expected FC=%d Payload=% X, got %s", recorded.FunctionCode, []byte(recorded.Payload), err)
+	}
+	replayed := session.Frame{UnitID: res.UnitId, FunctionCode: res.FunctionCode, Payload: res.Payload}
+	if replayed.Equal(*recorded) {
+		return ""
+	}
+	return fmt.Sprintf("expected FC=%d Payload=% X, got FC=%d Payload=% X",
+		recorded.FunctionCode, []byte(recorded.Payload), res.FunctionCode, res.Payload)
+}
@@ -14,9 +14,16 @@ import (
 	"github.com/rwirdemann/modbuslabs"
 	"github.com/rwirdemann/modbuslabs/config"
 	"github.com/rwirdemann/modbuslabs/console"
+	"github.com/rwirdemann/modbuslabs/fault"
+	"github.com/rwirdemann/modbuslabs/learn"
+	"github.com/rwirdemann/modbuslabs/netem"
+	"github.com/rwirdemann/modbuslabs/pty"
 	"github.com/rwirdemann/modbuslabs/rtu"
+	"github.com/rwirdemann/modbuslabs/schedule"
+	"github.com/rwirdemann/modbuslabs/session"
 	"github.com/rwirdemann/modbuslabs/socat"
 	"github.com/rwirdemann/modbuslabs/tcp"
+	"github.com/rwirdemann/modbuslabs/upstream"
 )
 
 func main() {
@@ -34,6 +41,8 @@ func run() int {
 	configFile := flag.String(
 		"config", defaultConfig, "path to TOML configuration file",
 	)
+	record := flag.String("record", "", "record all requests and responses to this session file")
+	learnFile := flag.String("learn", "", "write a slave configuration learned from proxied and routed traffic to this file on exit")
 	help := flag.Bool("help", false, "Print this help page.")
 	flag.Parse()
 
@@ -70,13 +79,6 @@ func run() int {
 		"slaves", len(cfg.Slaves),
 	)
 
-	stopSocat, err := socat.StartAll(cfg.Transports)
-	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "socat: %v\n", err)
-		return 1
-	}
-	defer stopSocat()
-
 	handlers, err := buildHandlers(cfg, protocolPort)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -88,18 +90,108 @@ func run() int {
 	)
 	defer cancel()
 
+	// Slaves and routes sharing a serial bus share its client, which sends
+	// one request at a time.
+	clients := make(map[string]*upstream.Client)
+	upstreamClient := func(u config.Upstream) (*upstream.Client, error) {
+		if c, exists := clients[u.Address]; exists {
+			return c, nil
+		}
+		c, err := upstream.NewClient(u.Address, upstreamOptions(u))
+		if err != nil {
+			return nil, err
+		}
+		clients[u.Address] = c
+		return c, nil
+	}
+
+	// The learner sees every exchange with a real device, i.e. the upstreams
+	// of proxy slaves and the buses of routes.
+	var learner *learn.Learner
+	if *learnFile != "" {
+		learner = learn.New()
+		defer func() {
+			if err := learner.Save(*learnFile); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			slog.Info("Learned slave configuration written", "file", *learnFile)
+		}()
+	}
+
 	modbus := modbuslabs.NewGateway(handlers, protocolPort)
+	for _, t := range cfg.Transports {
+		modbus.SetBroadcast(t.Address, t.BroadcastEnabled())
+		modbus.SetUnitIDRouting(t.Address, t.UnitIDRouting, t.DesignatedSlave)
+		if t.Promiscuous != "" {
+			modbus.SetPromiscuous(t.Address, *cfg.GetTemplate(t.Promiscuous))
+		}
+	}
+	for i, r := range cfg.Routes {
+		bus, err := upstreamClient(r.Bus)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "route %d: %v\n", i, err)
+			return 1
+		}
+		if learner != nil {
+			for _, id := range r.UnitIDs {
+				modbus.AddRoute(r.Address, []uint8{id}, learner.Upstream(bus, r.Address, id))
+			}
+		} else {
+			modbus.AddRoute(r.Address, r.UnitIDs, bus)
+		}
+		slog.Debug("Added route", "address", r.Address, "unitIDs", r.UnitIDs, "bus", r.Bus.Address)
+	}
+	if *record != "" {
+		recorder, err := session.Create(*record)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer recorder.Close()
+		modbus.Record(recorder)
+	}
 	if err := modbus.Start(ctx); err != nil {
-		panic(err)
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
 	}
 	defer modbus.Stop()
 
 	go console.NewKeyboardAdapter(modbus, protocolPort).Start(cancel)
 
 	for _, s := range cfg.Slaves {
+		if s.Type == "proxy" {
+			client, err := upstreamClient(s.Upstream)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "slave %d: %v\n", s.ID, err)
+				return 1
+			}
+			var up modbuslabs.Upstream = client
+			if learner != nil {
+				up = learner.Upstream(client, s.Address, s.ID)
+			}
+			modbus.ConnectProxySlave(s, s.Address, up)
+			slog.Debug("Connected proxy slave", "id", s.ID, "address", s.Address, "upstream", s.Upstream.Address)
+			continue
+		}
+		if s.Shadow.Address != "" {
+			reference, err := upstreamClient(s.Shadow.Upstream)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "slave %d: %v\n", s.ID, err)
+				return 1
+			}
+			modbus.ConnectShadowSlave(s, s.Address, reference)
+			slog.Debug("Connected shadow slave", "id", s.ID, "address", s.Address, "reference", s.Shadow.Address)
+			continue
+		}
 		modbus.ConnectSlaveWithConfig(s, s.Address)
 		slog.Debug("Connected slave", "id", s.ID, "address", s.Address)
 	}
+	for _, s := range cfg.Slaves {
+		if s.Schedule.Mode != "" {
+			go schedule.Run(ctx, slaveSchedule(s.Schedule), s.ID, s.Address, modbus, protocolPort)
+		}
+	}
 
 	<-ctx.Done()
 	return 0
@@ -112,10 +204,11 @@ func buildHandlers(
 ) ([]modbuslabs.TransportHandler, error) {
 	var handlers []modbuslabs.TransportHandler
 	for _, t := range cfg.Transports {
+		injector := faultInjector(cfg, t)
 		switch t.Type {
 		case "tcp":
 			h, err := tcp.NewHandler(
-				fmt.Sprintf("tcp://%s", t.Address), port,
+				fmt.Sprintf("tcp://%s", t.Address), tcpOptions(t, injector), port,
 			)
 			if err != nil {
 				return nil, fmt.Errorf(
@@ -123,13 +216,142 @@ func buildHandlers(
 				)
 			}
 			handlers = append(handlers, h)
+		case "tls":
+			tlsConfig, err := tcp.NewTLSConfig(t.CertFile, t.KeyFile, t.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("TLS handler %s: %w", t.Address, err)
+			}
+			h, err := tcp.NewTLSHandler(
+				fmt.Sprintf("tls://%s", t.Address), tcpOptions(t, injector), tlsConfig, port,
+			)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"TLS handler %s: %w", t.Address, err,
+				)
+			}
+			handlers = append(handlers, h)
+		case "unix":
+			h, err := tcp.NewHandler(
+				fmt.Sprintf("unix://%s", t.Address), tcpOptions(t, injector), port,
+			)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Unix handler %s: %w", t.Address, err,
+				)
+			}
+			handlers = append(handlers, h)
 		case "rtu":
-			handlers = append(handlers, rtu.NewHandler(t.Address, port))
+			handlers = append(handlers, rtu.NewHandler(t.Address, virtualPair(t, port), rtuOptions(t, injector), port))
 		}
 	}
 	return handlers, nil
 }
 
+// virtualPair returns the virtual serial port pair for an RTU transport. The
+// native pseudo-terminal pair is used unless socat is configured or native
+// pseudo-terminals are not supported on this platform.
+func virtualPair(t config.Transport, port modbuslabs.ProtocolPort) rtu.VirtualPair {
+	if t.Pty == "socat" || !pty.Supported() {
+		return socat.NewPair(t.Address, t.PeerAddress, port)
+	}
+	return pty.NewPair(t.Address, t.PeerAddress)
+}
+
+// faultInjector returns the injector for the faults configured for t and its
+// slaves, nil if there are none.
+func faultInjector(cfg *config.Config, t config.Transport) *fault.Injector {
+	slaves := make(map[uint8][]fault.Fault)
+	for _, s := range cfg.Slaves {
+		if s.Address == t.Address && len(s.Faults) > 0 {
+			slaves[s.ID] = faults(s.Faults)
+		}
+	}
+	if len(t.Faults) == 0 && len(slaves) == 0 {
+		return nil
+	}
+	return fault.NewInjector(faults(t.Faults), slaves)
+}
+
+// faults maps configured faults to fault.Fault.
+func faults(fs []config.Fault) []fault.Fault {
+	var mapped []fault.Fault
+	for _, f := range fs {
+		mapped = append(mapped, fault.Fault{Type: f.Type, Probability: f.Probability, MinDelay: f.MinDelay, MaxDelay: f.MaxDelay})
+	}
+	return mapped
+}
+
+// slaveSchedule maps a configured schedule to schedule.Schedule.
+func slaveSchedule(s config.Schedule) schedule.Schedule {
+	mapped := schedule.Schedule{Mode: s.Mode, Online: s.Online, Offline: s.Offline}
+	for _, e := range s.Events {
+		mapped.Events = append(mapped.Events, schedule.Event{At: e.At, After: e.After, Connected: e.Connected})
+	}
+	return mapped
+}
+
+// network returns the emulator for the configured network conditions, nil
+// if there are none.
+func network(n config.Network) *netem.Emulator {
+	if !n.Configured() {
+		return nil
+	}
+	return netem.New(netem.Options{
+		Latency:   n.Latency,
+		Jitter:    n.Jitter,
+		Bandwidth: n.Bandwidth,
+		BytePause: n.BytePause,
+		HalfOpen:  n.HalfOpen,
+		Reset:     n.Reset,
+	})
+}
+
+// rtuOptions maps the serial line settings of t to rtu.Options.
+func rtuOptions(t config.Transport, faults *fault.Injector) rtu.Options {
+	return rtu.Options{
+		BaudRate:        t.BaudRate,
+		DataBits:        t.DataBits,
+		Parity:          t.Parity,
+		StopBits:        t.StopBits,
+		LineTiming:      t.LineTiming,
+		TurnaroundDelay: t.TurnaroundDelay,
+		Echo:            t.Echo,
+		PcapFile:        t.PcapFile,
+		Faults:          faults,
+	}
+}
+
+// tcpOptions maps the connection management settings of t to tcp.Options.
+func tcpOptions(t config.Transport, faults *fault.Injector) tcp.Options {
+	return tcp.Options{
+		Mode:              t.Mode,
+		ReconnectDelay:    t.ReconnectDelay,
+		MaxReconnectDelay: t.MaxReconnectDelay,
+		MaxConnections:    t.MaxConnections,
+		Overflow:          t.Overflow,
+		ReadTimeout:       t.ReadTimeout,
+		IdleTimeout:       t.IdleTimeout,
+		ShutdownTimeout:   t.ShutdownTimeout,
+		Pipelining:        t.Pipelining,
+		ReorderWindow:     t.ReorderWindow,
+		PcapFile:          t.PcapFile,
+		Faults:            faults,
+		Network:           network(t.Network),
+	}
+}
+
+// upstreamOptions maps the upstream settings of a proxy slave or the bus
+// settings of a route to upstream.Options.
+func upstreamOptions(u config.Upstream) upstream.Options {
+	return upstream.Options{
+		Timeout:  u.Timeout,
+		BaudRate: u.BaudRate,
+		DataBits: u.DataBits,
+		Parity:   u.Parity,
+		StopBits: u.StopBits,
+	}
+}
+
 // getHomeDir returns the home directory, handling sudo correctly. When
 // running with sudo, it uses the original user's home directory.
 func getHomeDir() string {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rwirdemann/modbuslabs/console"
+	"github.com/rwirdemann/modbuslabs/learn"
+	"github.com/rwirdemann/modbuslabs/pty"
+	"github.com/rwirdemann/modbuslabs/sniffer"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	masterAddress := flag.String("master", "/tmp/ttyV1", "virtual serial port the master connects to")
+	slaveAddress := flag.String("slave", "/tmp/ttyS1", "virtual serial port the slave connects to")
+	learnFile := flag.String("learn", "", "write a slave configuration learned from the observed responses to this file on exit")
+	debug := flag.Bool("debug", false, "set log level to debug")
+	help := flag.Bool("help", false, "Print this help page.")
+	flag.Parse()
+
+	if *help {
+		flag.Usage()
+		return 0
+	}
+
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+
+	if !pty.Supported() {
+		_, _ = fmt.Fprintln(os.Stderr, pty.ErrUnsupported)
+		return 1
+	}
+
+	protocolPort := console.NewProtocolAdapter()
+	// Decoded frames are what the sniffer is for, show them right away.
+	protocolPort.Toggle()
+
+	s := sniffer.New(*masterAddress, *slaveAddress, protocolPort)
+	var learner *learn.Learner
+	if *learnFile != "" {
+		learner = learn.New()
+		s.SetLearner(learner)
+	}
+	if err := s.Open(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error creating virtual serial ports: %v\n", err)
+		return 1
+	}
+	defer s.Close()
+	protocolPort.Println(fmt.Sprintf("Sniffing master %s <-> slave %s, press Ctrl-C to stop", *masterAddress, *slaveAddress))
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(), syscall.SIGINT, syscall.SIGTERM,
+	)
+	defer cancel()
+	<-ctx.Done()
+
+	if learner != nil {
+		if err := learner.Save(*learnFile); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		protocolPort.Println(fmt.Sprintf("Learned slave configuration written to %s", *learnFile))
+	}
+	return 0
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -11,30 +15,449 @@ import (
 type Config struct {
 	Transports []Transport `toml:"transport"`
 	Slaves     []Slave     `toml:"slave"`
+	Routes     []Route     `toml:"route"`
+	Templates  []Template  `toml:"template"`
 }
 
-// Transport defines a transport handler (TCP or RTU)
+// Transport defines a transport handler (TCP, TLS, Unix domain socket or RTU)
 type Transport struct {
-	Type        string `toml:"type"`         // "tcp" or "rtu"
-	Address     string `toml:"address"`      // For TCP: "localhost:502", for RTU: "/tmp/virtualcom0"
+	Type        string `toml:"type"`         // "tcp", "tls", "unix" or "rtu"
+	Address     string `toml:"address"`      // For TCP: "localhost:502", for Unix: "/tmp/slavesim.sock", for RTU: "/tmp/virtualcom0"
 	PeerAddress string `toml:"peer_address"` // RTU only: client-side TTY, e.g. "/tmp/ttyV1"
+	Pty         string `toml:"pty"`          // RTU only: "native" (default) or "socat" to create the virtual port pair
+	CertFile    string `toml:"cert_file"`    // TLS only: server certificate (PEM)
+	KeyFile     string `toml:"key_file"`     // TLS only: server private key (PEM)
+	CAFile      string `toml:"ca_file"`      // TLS only: CA certificates used to verify client certificates (PEM)
+	PcapFile    string `toml:"pcap_file"`    // Record all requests and responses to this PCAP file
+	Broadcast   *bool  `toml:"broadcast"`    // Apply writes to unit ID 0 to all slaves, default true for rtu, false otherwise
+	Promiscuous string `toml:"promiscuous"`  // Create unknown slaves on their first request from the template with this name
+
+	// Serial line, RTU only
+	BaudRate        int           `toml:"baud_rate"`        // Default 9600
+	DataBits        int           `toml:"data_bits"`        // Default 8
+	Parity          string        `toml:"parity"`           // "N" (default), "E" or "O"
+	StopBits        int           `toml:"stop_bits"`        // Default 1
+	LineTiming      bool          `toml:"line_timing"`      // Pace responses to match baud rate and character framing
+	TurnaroundDelay time.Duration `toml:"turnaround_delay"` // Delay between request and response, e.g. "5ms"
+	Echo            bool          `toml:"echo"`             // Emulate RS-485 half-duplex echo of the request, the master must discard it
+
+	// Connection management, TCP, TLS and Unix only
+	Mode              string        `toml:"mode"`                // "listen" (default) or "dial" to connect to the master at address
+	ReconnectDelay    time.Duration `toml:"reconnect_delay"`     // Dial mode only: initial delay between connection attempts, default "1s"
+	MaxReconnectDelay time.Duration `toml:"max_reconnect_delay"` // Dial mode only: upper bound of the doubling reconnect delay, default "30s"
+	MaxConnections    int           `toml:"max_connections"`     // Maximum number of concurrent client connections, 0 = unlimited
+	Overflow          string        `toml:"overflow"`            // "reject" (default) or "evict_oldest" when max_connections is reached
+	ReadTimeout       time.Duration `toml:"read_timeout"`        // Maximum time to receive a started frame, e.g. "2s"
+	IdleTimeout       time.Duration `toml:"idle_timeout"`        // Close connections without requests after this duration, e.g. "5m"
+	ShutdownTimeout   time.Duration `toml:"shutdown_timeout"`    // Maximum time to drain in-flight requests on shutdown, default "5s"
+	Pipelining        int           `toml:"pipelining"`          // Maximum number of concurrently processed requests per connection
+	UnitIDRouting     string        `toml:"unit_id_routing"`     // "strict" (default), "any" or "0_255" to answer these unit IDs by designated_slave
+	DesignatedSlave   uint8         `toml:"designated_slave"`    // Slave answering requests routed by unit_id_routing
+	ReorderWindow     time.Duration `toml:"reorder_window"`      // Pipelining only: send responses collected within this window in reverse order
+
+	Faults  []Fault `toml:"fault"`   // Faults injected into the responses of all slaves on this transport
+	Network Network `toml:"network"` // TCP, TLS and Unix only: emulated network conditions of responses
+}
+
+// BroadcastEnabled reports whether writes to unit ID 0 are broadcast to all
+// slaves of the transport. Broadcasts are enabled by default on serial lines
+// only, as the Modbus TCP specification doesn't define them.
+func (t *Transport) BroadcastEnabled() bool {
+	if t.Broadcast != nil {
+		return *t.Broadcast
+	}
+	return t.Type == "rtu"
+}
+
+// Network defines the conditions of an emulated bad network link
+type Network struct {
+	Latency   time.Duration `toml:"latency"`    // Delay of every response, e.g. "200ms"
+	Jitter    time.Duration `toml:"jitter"`     // Random deviation of the latency, up to ± jitter
+	Bandwidth int           `toml:"bandwidth"`  // Bytes per second, 0 = unlimited
+	BytePause time.Duration `toml:"byte_pause"` // Write responses byte by byte with this pause in between
+	HalfOpen  float64       `toml:"half_open"`  // Probability of a response turning the connection half-open, 0 to 1
+	Reset     float64       `toml:"reset"`      // Probability of a TCP reset while writing a response, 0 to 1
+}
+
+// Configured reports whether any network condition is set
+func (n *Network) Configured() bool {
+	return *n != Network{}
 }
 
 // Slave defines a slave configuration
 type Slave struct {
-	ID      uint8  `toml:"id"`      // Slave ID (e.g., 101)
-	Address string `toml:"address"` // Reference to transport address
-	Rules   []Rule `toml:"rule"`    // Behavioral rules for this slave
+	ID              uint8           `toml:"id"`                // Slave ID (e.g., 101)
+	Address         string          `toml:"address"`           // Reference to transport address
+	Type            string          `toml:"type"`              // "local" (default) or "proxy" to forward requests to upstream
+	Upstream        Upstream        `toml:"upstream"`          // Proxy only: remote slave requests are forwarded to
+	Local           []RegisterRange `toml:"local"`             // Proxy only: register ranges served locally instead of upstream
+	Shadow          Shadow          `toml:"shadow"`            // Local only: reference slave requests are also sent to for comparison
+	Registers       []Register      `toml:"register"`          // Initial register values
+	Faults          []Fault         `toml:"fault"`             // Faults injected into the responses of this slave
+	Schedule        Schedule        `toml:"schedule"`          // Connects and disconnects the slave automatically
+	MinPollInterval time.Duration   `toml:"min_poll_interval"` // Requests following the previous one sooner are answered busy (exception 06)
+	Map             RegisterMap     `toml:"map"`               // Valid addresses, other addresses are answered with exception 02
+	Rules           []Rule          `toml:"rule"`              // Behavioral rules for this slave
+}
+
+// Register defines the initial values of consecutive registers starting at
+// address, e.g. as learned from a real device
+type Register struct {
+	Address uint16   `toml:"address"`
+	Values  []uint16 `toml:"values"`
+}
+
+// Upstream defines the remote slave of a proxy slave
+type Upstream struct {
+	Address  string        `toml:"address"`   // "tcp://192.168.1.10:502" or "rtu:///dev/ttyUSB0"
+	UnitID   uint8         `toml:"unit_id"`   // Unit ID of the remote slave, defaults to the slave ID
+	Timeout  time.Duration `toml:"timeout"`   // Response timeout, default "1s"
+	BaudRate int           `toml:"baud_rate"` // RTU only, default 9600
+	DataBits int           `toml:"data_bits"` // RTU only, default 8
+	Parity   string        `toml:"parity"`    // RTU only: "N" (default), "E" or "O"
+	StopBits int           `toml:"stop_bits"` // RTU only, default 1
+}
+
+// Shadow defines the reference slave a local slave is compared with, e.g.
+// the real device the slave simulates
+type Shadow struct {
+	Upstream
+	TimingThreshold time.Duration `toml:"timing_threshold"` // Report response times differing by more, e.g. "50ms", 0 = timing not compared
+}
+
+// Fault defines a fault injected into responses with the given probability
+type Fault struct {
+	Type        string        `toml:"type"`        // "drop", "delay", "corrupt_crc", "flip_bits", "truncate", "wrong_transaction_id" or "duplicate"
+	Probability float64       `toml:"probability"` // 0 to 1, e.g. 0.05 for 5% of the responses
+	MinDelay    time.Duration `toml:"min_delay"`   // Delay only: shortest delay, default "0s"
+	MaxDelay    time.Duration `toml:"max_delay"`   // Delay only: longest delay, e.g. "2s"
+}
+
+// faultTypes lists the valid fault types
+var faultTypes = []string{"drop", "delay", "corrupt_crc", "flip_bits", "truncate", "wrong_transaction_id", "duplicate"}
+
+// Validate checks the fault for a transport of the given type
+func (f *Fault) Validate(transportType string) error {
+	if !slices.Contains(faultTypes, f.Type) {
+		return fmt.Errorf("invalid type %q, must be one of: %s", f.Type, strings.Join(faultTypes, ", "))
+	}
+	if f.Probability <= 0 || f.Probability > 1 {
+		return fmt.Errorf("probability must be greater than 0 and at most 1")
+	}
+	if f.Type == "delay" && (f.MaxDelay <= 0 || f.MinDelay < 0 || f.MinDelay > f.MaxDelay) {
+		return fmt.Errorf("delay requires 0 <= min_delay <= max_delay and max_delay > 0")
+	}
+	if f.Type == "corrupt_crc" && transportType != "rtu" {
+		return fmt.Errorf("corrupt_crc requires an rtu transport")
+	}
+	if f.Type == "wrong_transaction_id" && transportType == "rtu" {
+		return fmt.Errorf("wrong_transaction_id requires a tcp, tls or unix transport")
+	}
+	return nil
+}
+
+// Template defines the initial registers and rules of slaves created on the
+// fly by promiscuous transports
+type Template struct {
+	Name            string        `toml:"name"`
+	Registers       []Register    `toml:"register"`          // Initial register values
+	Rules           []Rule        `toml:"rule"`              // Behavioral rules
+	MinPollInterval time.Duration `toml:"min_poll_interval"` // See Slave
+	Map             RegisterMap   `toml:"map"`               // See Slave
+}
+
+// Slave returns the configuration of the slave with id on the transport at
+// address created from the template.
+func (t *Template) Slave(id uint8, address string) Slave {
+	return Slave{ID: id, Address: address, Registers: t.Registers, Rules: t.Rules, MinPollInterval: t.MinPollInterval, Map: t.Map}
+}
+
+// GetTemplate returns the template with the given name or nil if there is no
+// such template.
+func (c *Config) GetTemplate(name string) *Template {
+	for i := range c.Templates {
+		if c.Templates[i].Name == name {
+			return &c.Templates[i]
+		}
+	}
+	return nil
+}
+
+// Schedule defines when a slave is connected and disconnected automatically
+type Schedule struct {
+	Mode    string          `toml:"mode"`    // "periodic", "random" or "events"
+	Online  time.Duration   `toml:"online"`  // Periodic: time connected, random: mean time between outages, e.g. "30s"
+	Offline time.Duration   `toml:"offline"` // Periodic: time disconnected, random: mean outage duration, e.g. "5s"
+	Events  []ScheduleEvent `toml:"event"`   // Events only: explicit changes
+}
+
+// ScheduleEvent connects or disconnects a slave at a point in time
+type ScheduleEvent struct {
+	At        time.Time     `toml:"at"`        // Time of the change, e.g. 2026-10-18T14:30:00+02:00
+	After     time.Duration `toml:"after"`     // Or time since start, e.g. "1m30s"
+	Connected bool          `toml:"connected"` // State after the change
+}
+
+// Validate checks if a schedule is valid
+func (s *Schedule) Validate() error {
+	switch s.Mode {
+	case "":
+		if len(s.Events) > 0 || s.Online != 0 || s.Offline != 0 {
+			return fmt.Errorf("mode is required")
+		}
+	case "periodic", "random":
+		if s.Online <= 0 || s.Offline <= 0 {
+			return fmt.Errorf("mode %q requires positive online and offline durations", s.Mode)
+		}
+	case "events":
+		if len(s.Events) == 0 {
+			return fmt.Errorf("mode 'events' requires at least one event")
+		}
+		for i, e := range s.Events {
+			if e.At.IsZero() == (e.After == 0) {
+				return fmt.Errorf("event[%d]: exactly one of at and after is required", i)
+			}
+			if e.After < 0 {
+				return fmt.Errorf("event[%d]: after must not be negative", i)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid mode %q, must be 'periodic', 'random' or 'events'", s.Mode)
+	}
+	return nil
+}
+
+// Route forwards requests for unit IDs received on a TCP transport to a
+// serial bus, with slavesim acting as master on the bus
+type Route struct {
+	Address string   `toml:"address"`  // Reference to a tcp, tls or unix transport address
+	UnitIDs []uint8  `toml:"unit_ids"` // Unit IDs of the slaves on the bus
+	Bus     Upstream `toml:"bus"`      // Serial bus, e.g. address = "rtu:///dev/ttyUSB0"; unit_id is not used
+}
+
+// RegisterMap declares the valid addresses of a slave per data table.
+// Tables without ranges accept every address
+type RegisterMap struct {
+	Coils            []RegisterRange `toml:"coils"`             // FC1, FC5, FC15
+	DiscreteInputs   []RegisterRange `toml:"discrete_inputs"`   // FC2
+	HoldingRegisters []RegisterRange `toml:"holding_registers"` // FC3, FC6, FC16, FC17
+	InputRegisters   []RegisterRange `toml:"input_registers"`   // FC4
+	Access           []Access        `toml:"access"`            // Access attributes of holding register and coil ranges
+	Constraints      []Constraint    `toml:"constraint"`        // Valid values of holding registers
+}
+
+// Constraint restricts the values written to a holding register, or to two
+// consecutive holding registers for float32 and int32 values (high word
+// first). Writes violating it are answered with exception 03 or clamped.
+type Constraint struct {
+	Register uint16    `toml:"register"` // Holding register, the first one of 32 bit values
+	Type     string    `toml:"type"`     // "uint16" (default), "int16", "int32" or "float32"
+	Min      *float64  `toml:"min"`      // Smallest valid value
+	Max      *float64  `toml:"max"`      // Largest valid value
+	Enum     []float64 `toml:"enum"`     // Valid values
+	Mask     *uint32   `toml:"mask"`     // Bits that may be set, not for float32
+	Clamp    bool      `toml:"clamp"`    // Clamp to min/max and clear bits outside mask instead of exception 03
+}
+
+// DataType returns the type of the constrained value.
+func (c *Constraint) DataType() string {
+	if c.Type == "" {
+		return "uint16"
+	}
+	return c.Type
+}
+
+// Words returns the number of registers holding the value.
+func (c *Constraint) Words() int {
+	if c.DataType() == "int32" || c.DataType() == "float32" {
+		return 2
+	}
+	return 1
+}
+
+// representable reports whether v is a value of the constraint's type,
+// integral and within its range for integer types
+func (c *Constraint) representable(v float64) bool {
+	var lo, hi float64
+	switch c.DataType() {
+	case "float32":
+		return math.Abs(v) <= math.MaxFloat32
+	case "int16":
+		lo, hi = math.MinInt16, math.MaxInt16
+	case "int32":
+		lo, hi = math.MinInt32, math.MaxInt32
+	default:
+		lo, hi = 0, math.MaxUint16
+	}
+	return v == math.Trunc(v) && v >= lo && v <= hi
+}
+
+// Validate checks the type and that the limits are consistent and valid
+// values of the type
+func (c *Constraint) Validate() error {
+	validTypes := map[string]bool{
+		"uint16":  true,
+		"int16":   true,
+		"int32":   true,
+		"float32": true,
+	}
+	if !validTypes[c.DataType()] {
+		return fmt.Errorf("invalid type: %s (must be uint16, int16, int32 or float32)", c.Type)
+	}
+	if c.Words() == 2 && c.Register == 0xFFFF {
+		return fmt.Errorf("%s value at register 0xFFFF exceeds the address space", c.Type)
+	}
+	if c.Min == nil && c.Max == nil && len(c.Enum) == 0 && c.Mask == nil {
+		return fmt.Errorf("min, max, enum or mask is required")
+	}
+	if c.Min != nil && !c.representable(*c.Min) {
+		return fmt.Errorf("min %g is not a valid %s value", *c.Min, c.DataType())
+	}
+	if c.Max != nil && !c.representable(*c.Max) {
+		return fmt.Errorf("max %g is not a valid %s value", *c.Max, c.DataType())
+	}
+	for _, v := range c.Enum {
+		if !c.representable(v) {
+			return fmt.Errorf("enum value %g is not a valid %s value", v, c.DataType())
+		}
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return fmt.Errorf("min must not be greater than max")
+	}
+	if c.Mask != nil {
+		if c.DataType() == "float32" {
+			return fmt.Errorf("mask is not supported for float32")
+		}
+		if c.Words() == 1 && *c.Mask > 0xFFFF {
+			return fmt.Errorf("mask exceeds 16 bits")
+		}
+	}
+	return nil
+}
+
+// Access restricts the access to count consecutive holding registers or
+// coils. With an unlock sequence, the range is only writable after the
+// sequence has been written to the unlock register, e.g. a password to a key
+// register.
+type Access struct {
+	Table          string        `toml:"table"`           // "holding_registers" (default) or "coils"
+	Start          uint16        `toml:"start"`           // First address
+	Count          uint16        `toml:"count"`           // Number of addresses
+	Mode           string        `toml:"mode"`            // "read_write" (default), "read_only", "write_only" or "write_once"
+	UnlockRegister uint16        `toml:"unlock_register"` // Holding register the unlock sequence is written to
+	UnlockSequence []uint16      `toml:"unlock_sequence"` // Values to write in order, the range is locked if set
+	UnlockTimeout  time.Duration `toml:"unlock_timeout"`  // Locks the range again after this duration, never if 0
+	Exception      uint8         `toml:"exception"`       // Exception code of denied accesses, 02 if 0
+}
+
+// DataTable returns the data table of the range.
+func (a *Access) DataTable() string {
+	if a.Table == "" {
+		return "holding_registers"
+	}
+	return a.Table
+}
+
+// Contains reports whether addr lies within the range.
+func (a *Access) Contains(addr uint16) bool {
+	return RegisterRange{Start: a.Start, Count: a.Count}.Contains(addr)
+}
+
+// Validate checks the table, range, mode and unlock settings
+func (a *Access) Validate() error {
+	if a.DataTable() != "holding_registers" && a.DataTable() != "coils" {
+		return fmt.Errorf("invalid table: %s (must be holding_registers or coils)", a.Table)
+	}
+	if err := (RegisterRange{Start: a.Start, Count: a.Count}).Validate(); err != nil {
+		return err
+	}
+	validModes := map[string]bool{
+		"":           true,
+		"read_write": true,
+		"read_only":  true,
+		"write_only": true,
+		"write_once": true,
+	}
+	if !validModes[a.Mode] {
+		return fmt.Errorf("invalid mode: %s (must be read_write, read_only, write_only or write_once)", a.Mode)
+	}
+	if a.UnlockTimeout < 0 {
+		return fmt.Errorf("unlock_timeout must not be negative")
+	}
+	if a.UnlockTimeout > 0 && len(a.UnlockSequence) == 0 {
+		return fmt.Errorf("unlock_timeout requires unlock_sequence")
+	}
+	if len(a.UnlockSequence) > 0 && a.DataTable() == "holding_registers" && a.Contains(a.UnlockRegister) {
+		return fmt.Errorf("unlock_register 0x%04X must not lie within the locked range", a.UnlockRegister)
+	}
+	return nil
+}
+
+// Validate checks that all ranges are non-empty and within the address space
+// and that the access attributes and constraints are valid
+func (m *RegisterMap) Validate() error {
+	for _, table := range []struct {
+		name   string
+		ranges []RegisterRange
+	}{
+		{"coils", m.Coils}, {"discrete_inputs", m.DiscreteInputs},
+		{"holding_registers", m.HoldingRegisters}, {"input_registers", m.InputRegisters},
+	} {
+		for i, r := range table.ranges {
+			if err := r.Validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %w", table.name, i, err)
+			}
+		}
+	}
+	for i, a := range m.Access {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("access[%d]: %w", i, err)
+		}
+	}
+	for i, c := range m.Constraints {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("constraint[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// RegisterRange defines count consecutive registers starting at start
+type RegisterRange struct {
+	Start uint16 `toml:"start"`
+	Count uint16 `toml:"count"`
+}
+
+// Validate checks that the range is non-empty and within the address space
+func (r RegisterRange) Validate() error {
+	if r.Count == 0 || uint32(r.Start)+uint32(r.Count) > 0x10000 {
+		return fmt.Errorf("count must be positive and the range must not exceed address 0xFFFF")
+	}
+	return nil
+}
+
+// End returns the last address of the range.
+func (r RegisterRange) End() uint32 {
+	return uint32(r.Start) + uint32(r.Count) - 1
+}
+
+// Contains reports whether addr lies within the range.
+func (r RegisterRange) Contains(addr uint16) bool {
+	return addr >= r.Start && uint32(addr) < uint32(r.Start)+uint32(r.Count)
 }
 
 // Rule defines a behavior rule for a slave
 type Rule struct {
-	Trigger       string  `toml:"trigger"`        // "on_read", "on_write", "on_read_write"
-	Register      uint16  `toml:"register"`       // Register address (hex or decimal)
-	Action        string  `toml:"action"`         // "set_value", "increment", "decrement", "toggle", "write_register"
-	Value         *uint16 `toml:"value"`          // Optional: Value for set_value action OR condition value for on_write trigger
-	WriteRegister *uint16 `toml:"write_register"` // Optional: Target register for write_register action
-	WriteValue    *uint16 `toml:"write_value"`    // Optional: Value to write for write_register action
+	Trigger       string        `toml:"trigger"`        // "on_read", "on_write", "on_read_write"
+	Register      uint16        `toml:"register"`       // Register address (hex or decimal)
+	Action        string        `toml:"action"`         // "set_value", "increment", "decrement", "toggle", "write_register", "require_role", "busy"
+	Value         *uint16       `toml:"value"`          // Optional: Value for set_value action OR condition value for on_write trigger
+	WriteRegister *uint16       `toml:"write_register"` // Optional: Target register for write_register action
+	WriteValue    *uint16       `toml:"write_value"`    // Optional: Value to write for write_register action
+	Roles         []string      `toml:"roles"`          // Optional: Roles allowed to write the register for require_role action
+	Duration      time.Duration `toml:"duration"`       // Busy only: length of the busy window, e.g. "5s"
+	FunctionCodes []uint8       `toml:"function_codes"` // Busy only: function codes answered busy, default all
+	Acknowledge   bool          `toml:"acknowledge"`    // Busy only: answer the triggering request with exception 05
 }
 
 // Load reads and parses a TOML configuration file
@@ -62,11 +485,37 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one transport must be defined")
 	}
 
+	// Check templates first, promiscuous transports refer to them
+	templateNames := make(map[string]bool)
+	for i, t := range c.Templates {
+		if t.Name == "" {
+			return fmt.Errorf("template[%d]: name is required", i)
+		}
+		if templateNames[t.Name] {
+			return fmt.Errorf("template[%d]: duplicate name %q", i, t.Name)
+		}
+		templateNames[t.Name] = true
+		for j, r := range t.Registers {
+			if len(r.Values) == 0 || int(r.Address)+len(r.Values) > 0x10000 {
+				return fmt.Errorf("template[%d].register[%d]: values must not be empty or exceed address 0xFFFF", i, j)
+			}
+		}
+		for j, rule := range t.Rules {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("template[%d].rule[%d]: %w", i, j, err)
+			}
+		}
+		if err := t.Map.Validate(); err != nil {
+			return fmt.Errorf("template[%d].map.%w", i, err)
+		}
+	}
+
 	// Check that all transports have valid types
 	transportAddresses := make(map[string]bool)
+	transportTypes := make(map[string]string)
 	for i, t := range c.Transports {
-		if t.Type != "tcp" && t.Type != "rtu" {
-			return fmt.Errorf("transport[%d]: invalid type %q, must be 'tcp' or 'rtu'", i, t.Type)
+		if t.Type != "tcp" && t.Type != "tls" && t.Type != "unix" && t.Type != "rtu" {
+			return fmt.Errorf("transport[%d]: invalid type %q, must be 'tcp', 'tls', 'unix' or 'rtu'", i, t.Type)
 		}
 		if t.Address == "" {
 			return fmt.Errorf("transport[%d]: address is required", i)
@@ -77,7 +526,69 @@ func (c *Config) Validate() error {
 				i,
 			)
 		}
+		if t.Pty != "" && t.Pty != "native" && t.Pty != "socat" {
+			return fmt.Errorf("transport[%d]: invalid pty %q, must be 'native' or 'socat'", i, t.Pty)
+		}
+		if t.Parity != "" && t.Parity != "N" && t.Parity != "E" && t.Parity != "O" {
+			return fmt.Errorf("transport[%d]: invalid parity %q, must be 'N', 'E' or 'O'", i, t.Parity)
+		}
+		if t.BaudRate < 0 || t.DataBits < 0 || t.StopBits < 0 {
+			return fmt.Errorf("transport[%d]: baud_rate, data_bits and stop_bits must not be negative", i)
+		}
+		if t.Type == "tls" && (t.CertFile == "" || t.KeyFile == "" || t.CAFile == "") {
+			return fmt.Errorf(
+				"transport[%d]: cert_file, key_file and ca_file required for tls transport",
+				i,
+			)
+		}
+		if t.Overflow != "" && t.Overflow != "reject" && t.Overflow != "evict_oldest" {
+			return fmt.Errorf("transport[%d]: invalid overflow %q, must be 'reject' or 'evict_oldest'", i, t.Overflow)
+		}
+		if t.Mode != "" && t.Mode != "listen" && t.Mode != "dial" {
+			return fmt.Errorf("transport[%d]: invalid mode %q, must be 'listen' or 'dial'", i, t.Mode)
+		}
+		if t.Mode == "dial" && t.Type == "rtu" {
+			return fmt.Errorf("transport[%d]: mode 'dial' not supported for rtu transport", i)
+		}
+		if t.MaxConnections < 0 {
+			return fmt.Errorf("transport[%d]: max_connections must not be negative", i)
+		}
+		if t.ReorderWindow > 0 && t.Pipelining < 2 {
+			return fmt.Errorf("transport[%d]: reorder_window requires pipelining of at least 2", i)
+		}
+		if t.UnitIDRouting != "" && t.UnitIDRouting != "strict" && t.UnitIDRouting != "any" && t.UnitIDRouting != "0_255" {
+			return fmt.Errorf("transport[%d]: invalid unit_id_routing %q, must be 'strict', 'any' or '0_255'", i, t.UnitIDRouting)
+		}
+		if t.UnitIDRouting == "any" || t.UnitIDRouting == "0_255" {
+			if t.Type == "rtu" {
+				return fmt.Errorf("transport[%d]: unit_id_routing requires a tcp, tls or unix transport", i)
+			}
+			if !slices.ContainsFunc(c.Slaves, func(s Slave) bool { return s.Address == t.Address && s.ID == t.DesignatedSlave }) {
+				return fmt.Errorf("transport[%d]: designated_slave %d is not a slave of this transport", i, t.DesignatedSlave)
+			}
+			if t.Broadcast != nil && *t.Broadcast {
+				return fmt.Errorf("transport[%d]: broadcast can't be combined with unit_id_routing %q", i, t.UnitIDRouting)
+			}
+		}
+		if t.Promiscuous != "" && !templateNames[t.Promiscuous] {
+			return fmt.Errorf("transport[%d]: promiscuous template %q is not defined", i, t.Promiscuous)
+		}
+		if t.Network.Configured() && t.Type == "rtu" {
+			return fmt.Errorf("transport[%d]: network requires a tcp, tls or unix transport", i)
+		}
+		if n := t.Network; n.Latency < 0 || n.Jitter < 0 || n.Bandwidth < 0 || n.BytePause < 0 {
+			return fmt.Errorf("transport[%d]: network latency, jitter, bandwidth and byte_pause must not be negative", i)
+		}
+		if n := t.Network; n.HalfOpen < 0 || n.HalfOpen > 1 || n.Reset < 0 || n.Reset > 1 {
+			return fmt.Errorf("transport[%d]: network half_open and reset must be between 0 and 1", i)
+		}
+		for j, f := range t.Faults {
+			if err := f.Validate(t.Type); err != nil {
+				return fmt.Errorf("transport[%d].fault[%d]: %w", i, j, err)
+			}
+		}
 		transportAddresses[t.Address] = true
+		transportTypes[t.Address] = t.Type
 	}
 
 	// Check that all slaves reference valid transports
@@ -88,6 +599,52 @@ func (c *Config) Validate() error {
 		if !transportAddresses[s.Address] {
 			return fmt.Errorf("slave[%d]: address %q does not match any transport", i, s.Address)
 		}
+		if s.Type != "" && s.Type != "local" && s.Type != "proxy" {
+			return fmt.Errorf("slave[%d]: invalid type %q, must be 'local' or 'proxy'", i, s.Type)
+		}
+		if s.Type == "proxy" && !strings.HasPrefix(s.Upstream.Address, "tcp://") && !strings.HasPrefix(s.Upstream.Address, "rtu://") {
+			return fmt.Errorf("slave[%d]: upstream address of proxy slave must start with 'tcp://' or 'rtu://'", i)
+		}
+		if s.Type != "proxy" && len(s.Local) > 0 {
+			return fmt.Errorf("slave[%d]: local register ranges require type 'proxy'", i)
+		}
+		for j, r := range s.Local {
+			if err := r.Validate(); err != nil {
+				return fmt.Errorf("slave[%d].local[%d]: %w", i, j, err)
+			}
+		}
+		if p := s.Upstream.Parity; p != "" && p != "N" && p != "E" && p != "O" {
+			return fmt.Errorf("slave[%d]: invalid upstream parity %q, must be 'N', 'E' or 'O'", i, p)
+		}
+		if s.Shadow.Address != "" {
+			if s.Type == "proxy" {
+				return fmt.Errorf("slave[%d]: shadow requires type 'local'", i)
+			}
+			if !strings.HasPrefix(s.Shadow.Address, "tcp://") && !strings.HasPrefix(s.Shadow.Address, "rtu://") {
+				return fmt.Errorf("slave[%d]: shadow address must start with 'tcp://' or 'rtu://'", i)
+			}
+			if p := s.Shadow.Parity; p != "" && p != "N" && p != "E" && p != "O" {
+				return fmt.Errorf("slave[%d]: invalid shadow parity %q, must be 'N', 'E' or 'O'", i, p)
+			}
+		}
+
+		for j, r := range s.Registers {
+			if len(r.Values) == 0 || int(r.Address)+len(r.Values) > 0x10000 {
+				return fmt.Errorf("slave[%d].register[%d]: values must not be empty or exceed address 0xFFFF", i, j)
+			}
+		}
+
+		for j, f := range s.Faults {
+			if err := f.Validate(transportTypes[s.Address]); err != nil {
+				return fmt.Errorf("slave[%d].fault[%d]: %w", i, j, err)
+			}
+		}
+		if err := s.Schedule.Validate(); err != nil {
+			return fmt.Errorf("slave[%d].schedule: %w", i, err)
+		}
+		if err := s.Map.Validate(); err != nil {
+			return fmt.Errorf("slave[%d].map.%w", i, err)
+		}
 
 		// Validate rules
 		for j, rule := range s.Rules {
@@ -97,6 +654,32 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Check that routes start at TCP transports and don't hide slaves
+	for i, r := range c.Routes {
+		if !transportAddresses[r.Address] {
+			return fmt.Errorf("route[%d]: address %q does not match any transport", i, r.Address)
+		}
+		if transportTypes[r.Address] == "rtu" {
+			return fmt.Errorf("route[%d]: routes require a tcp, tls or unix transport", i)
+		}
+		if len(r.UnitIDs) == 0 {
+			return fmt.Errorf("route[%d]: unit_ids is required", i)
+		}
+		if !strings.HasPrefix(r.Bus.Address, "rtu://") {
+			return fmt.Errorf("route[%d]: bus address must start with 'rtu://'", i)
+		}
+		if p := r.Bus.Parity; p != "" && p != "N" && p != "E" && p != "O" {
+			return fmt.Errorf("route[%d]: invalid bus parity %q, must be 'N', 'E' or 'O'", i, p)
+		}
+		for _, id := range r.UnitIDs {
+			for _, s := range c.Slaves {
+				if s.Address == r.Address && s.ID == id {
+					return fmt.Errorf("route[%d]: unit %d is also configured as slave on %s", i, id, r.Address)
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -127,9 +710,11 @@ func (r *Rule) Validate() error {
 		"decrement":      true,
 		"toggle":         true,
 		"write_register": true,
+		"require_role":   true,
+		"busy":           true,
 	}
 	if !validActions[r.Action] {
-		return fmt.Errorf("invalid action %q, must be one of: set_value, increment, decrement, toggle, write_register", r.Action)
+		return fmt.Errorf("invalid action %q, must be one of: set_value, increment, decrement, toggle, write_register, require_role, busy", r.Action)
 	}
 
 	// Validate action-specific requirements
@@ -146,5 +731,23 @@ func (r *Rule) Validate() error {
 		}
 	}
 
+	if r.Action == "require_role" {
+		if r.Trigger != "on_write" {
+			return fmt.Errorf("require_role action requires 'on_write' trigger")
+		}
+		if len(r.Roles) == 0 {
+			return fmt.Errorf("require_role action requires 'roles' field")
+		}
+	}
+
+	if r.Action == "busy" {
+		if r.Trigger == "on_read_write" {
+			return fmt.Errorf("busy action requires 'on_read' or 'on_write' trigger")
+		}
+		if r.Duration <= 0 {
+			return fmt.Errorf("busy action requires a positive 'duration' field")
+		}
+	}
+
 	return nil
 }
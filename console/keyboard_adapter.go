@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/rwirdemann/modbuslabs"
@@ -150,6 +151,73 @@ func (a *KeyboardAdapter) Start(cancel context.CancelFunc) {
 				h.Uint16(), unitID, parts[3],
 			))
 			a.protocolPort.Separator()
+		case "faults", "f":
+			if len(parts) < 2 || (parts[1] != "on" && parts[1] != "off") {
+				a.protocolPort.Println("Error: usage: f <on|off> [url]")
+				a.protocolPort.Separator()
+				continue
+			}
+			url := ""
+			if len(parts) > 2 {
+				url = parts[2]
+			}
+			if err := a.simulator.SetFaults(url, parts[1] == "on"); err != nil {
+				a.protocolPort.Println(fmt.Sprintf("Error: %s", err))
+				a.protocolPort.Separator()
+				continue
+			}
+			a.protocolPort.Println(fmt.Sprintf("Fault injection switched %s", parts[1]))
+			a.protocolPort.Separator()
+		case "network", "n":
+			if len(parts) < 2 || (parts[1] != "on" && parts[1] != "off") {
+				a.protocolPort.Println("Error: usage: n <on|off> [url]")
+				a.protocolPort.Separator()
+				continue
+			}
+			url := ""
+			if len(parts) > 2 {
+				url = parts[2]
+			}
+			if err := a.simulator.SetNetwork(url, parts[1] == "on"); err != nil {
+				a.protocolPort.Println(fmt.Sprintf("Error: %s", err))
+				a.protocolPort.Separator()
+				continue
+			}
+			a.protocolPort.Println(fmt.Sprintf("Network emulation switched %s", parts[1]))
+			a.protocolPort.Separator()
+		case "busy", "b":
+			if len(parts) < 3 {
+				a.protocolPort.Println("Error: usage: b <unitID> <duration> [fc,...]")
+				a.protocolPort.Separator()
+				continue
+			}
+			unitID, err := strconv.ParseUint(parts[1], 10, 8)
+			if err != nil {
+				a.protocolPort.Println(fmt.Sprintf("Error: invalid unit ID '%s'", parts[1]))
+				a.protocolPort.Separator()
+				continue
+			}
+			d, err := time.ParseDuration(parts[2])
+			if err != nil || d < 0 {
+				a.protocolPort.Println(fmt.Sprintf("Error: invalid duration '%s', e.g. 5s", parts[2]))
+				a.protocolPort.Separator()
+				continue
+			}
+			var fcs []uint8
+			if len(parts) > 3 {
+				if fcs, err = parseFunctionCodes(parts[3]); err != nil {
+					a.protocolPort.Println(fmt.Sprintf("Error: %s", err))
+					a.protocolPort.Separator()
+					continue
+				}
+			}
+			if err := a.simulator.SetBusy(uint8(unitID), d, fcs); err != nil {
+				a.protocolPort.Println(fmt.Sprintf("Error: %s", err))
+				a.protocolPort.Separator()
+				continue
+			}
+			a.protocolPort.Println(fmt.Sprintf("Slave %d busy for %s", unitID, d))
+			a.protocolPort.Separator()
 		case "help", "h":
 			a.protocolPort.Println("Commands:")
 			a.protocolPort.Println("  quit/exit/q                       - Quit simulator")
@@ -159,6 +227,9 @@ func (a *KeyboardAdapter) Start(cancel context.CancelFunc) {
 			a.protocolPort.Println("  connect/c <unitID> <url>          - Connect slave")
 			a.protocolPort.Println("  disconnect/d <unitID>             - Disconnect slave")
 			a.protocolPort.Println("  write/w <unitID> <addr> <value>   - Write register value")
+			a.protocolPort.Println("  faults/f <on|off> [url]           - Switch fault injection")
+			a.protocolPort.Println("  network/n <on|off> [url]          - Switch network emulation")
+			a.protocolPort.Println("  busy/b <unitID> <duration> [fcs]  - Answer busy, e.g. 'b 1 10s 3,4'")
 			a.protocolPort.Println("  toggle/t                          - Toggle output format")
 			a.protocolPort.Println("  help/h                            - Show help")
 			a.protocolPort.Separator()
@@ -169,6 +240,20 @@ func (a *KeyboardAdapter) Start(cancel context.CancelFunc) {
 	}
 }
 
+// parseFunctionCodes parses a comma separated list of function codes, e.g.
+// "3,4,16".
+func parseFunctionCodes(v string) ([]uint8, error) {
+	var fcs []uint8
+	for _, s := range strings.Split(v, ",") {
+		fc, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid function code '%s'", s)
+		}
+		fcs = append(fcs, uint8(fc))
+	}
+	return fcs, nil
+}
+
 // parseWriteValue infers the type of v and returns the corresponding
 // uint16 register values. bool maps to 0/1, decimal numbers to two
 // float32 registers (high, low), integers to a single uint16.
@@ -5,13 +5,17 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rwirdemann/modbuslabs/message"
 	"golang.org/x/term"
 )
 
+// ProtocolAdapter writes the protocol output. It is safe for concurrent use
+// by the transports.
 type ProtocolAdapter struct {
+	lock             sync.Mutex
 	muted            bool
 	loglevel         message.Type
 	writer           io.Writer
@@ -26,10 +30,14 @@ func NewProtocolAdapter() *ProtocolAdapter {
 }
 
 func (p *ProtocolAdapter) SetWriter(w io.Writer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.writer = w
 }
 
 func (p *ProtocolAdapter) InfoX(m message.Message) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	if m.Type() == p.loglevel {
 		p.lastWasSeparator = false
 		ts := time.Now().Format(time.DateTime)
@@ -38,23 +46,34 @@ func (p *ProtocolAdapter) InfoX(m message.Message) {
 }
 
 func (p *ProtocolAdapter) Toggle() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lastWasSeparator = false
 	switch p.loglevel {
 	case message.TypeEncoded:
 		p.loglevel = message.TypeUnencoded
-		p.Println("loglevel set to 'Unencoded'")
+		p.print("loglevel set to 'Unencoded'", true)
 	case message.TypeUnencoded:
 		p.loglevel = message.TypeEncoded
-		p.Println("loglevel set to 'Encoded'")
+		p.print("loglevel set to 'Encoded'", true)
 	}
 }
 
 func (p *ProtocolAdapter) Info(msg string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.lastWasSeparator = false
 	ts := time.Now().Format(time.DateTime)
 	p.print(fmt.Sprintf("%s %s", ts, msg), false)
 }
 
 func (p *ProtocolAdapter) Separator() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.separator()
+}
+
+func (p *ProtocolAdapter) separator() {
 	if p.lastWasSeparator {
 		return
 	}
@@ -67,20 +86,28 @@ func (p *ProtocolAdapter) Separator() {
 }
 
 func (p *ProtocolAdapter) ForceSeparator() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.lastWasSeparator = false
-	p.Separator()
+	p.separator()
 }
 
 func (p *ProtocolAdapter) Println(msg string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.lastWasSeparator = false
 	p.print(msg, true)
 }
 
 func (p *ProtocolAdapter) Mute() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.muted = true
 }
 
 func (p *ProtocolAdapter) Unmute() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.muted = false
 }
 
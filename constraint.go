@@ -0,0 +1,191 @@
+package modbuslabs
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/encoding"
+)
+
+// writeValueOffsets are the payload offsets of the values written by FC6,
+// FC16 and FC17.
+var writeValueOffsets = map[uint8]int{
+	FC6WriteSingleRegister:         2,
+	FC16WriteMultipleRegisters:     5,
+	FC17ReadWriteMultipleRegisters: 9,
+}
+
+// constrain checks the values pdu writes to registers with constraints. It
+// returns pdu with clamped values and what was clamped, or why pdu is
+// answered with exception 03 and true.
+func (s *Slave) constrain(pdu PDU) (PDU, []string, bool) {
+	offset, writes := writeValueOffsets[pdu.FunctionCode]
+	if !writes || len(s.registerMap.Constraints) == 0 {
+		return pdu, nil, false
+	}
+	addr, quantity, ok := WriteRange(pdu)
+	if !ok {
+		return pdu, nil, false
+	}
+	values := writeValues(pdu)
+	quantity = min(quantity, uint16(len(values)))
+
+	var clamped []string
+	copied := false
+	for _, c := range s.registerMap.Constraints {
+		// The value's registers not written by pdu keep their current value
+		words := make([]uint16, c.Words())
+		var written []int
+		for w := range words {
+			a := c.Register + uint16(w)
+			if i := int(a) - int(addr); i >= 0 && i < int(quantity) {
+				words[w] = values[i]
+				written = append(written, i)
+			} else {
+				words[w] = s.registers[a]
+			}
+		}
+		if len(written) == 0 {
+			continue
+		}
+
+		adjusted, reason, ok := checkConstraint(c, words)
+		if !ok {
+			return pdu, []string{fmt.Sprintf("holding register 0x%04X: %s", c.Register, reason)}, true
+		}
+		if reason == "" {
+			continue
+		}
+		if len(written) < len(words) {
+			return pdu, []string{fmt.Sprintf("holding register 0x%04X: %s, can't clamp a partial write", c.Register, reason)}, true
+		}
+		if !copied {
+			pdu.Payload = slices.Clone(pdu.Payload)
+			copied = true
+		}
+		for w, i := range written {
+			copy(pdu.Payload[offset+2*i:offset+2*i+2], encoding.Uint16ToBytes(adjusted[w]))
+		}
+		clamped = append(clamped, fmt.Sprintf("holding register 0x%04X: %s", c.Register, reason))
+	}
+	return pdu, clamped, false
+}
+
+// checkConstraint checks the value held by words against c. It returns the
+// words of the clamped value and why it was clamped, or why the value
+// violates c and false.
+func checkConstraint(c config.Constraint, words []uint16) ([]uint16, string, bool) {
+	var reasons []string
+	if c.Mask != nil {
+		raw := uint32(words[0])
+		if len(words) == 2 {
+			raw = raw<<16 | uint32(words[1])
+		}
+		if outside := raw &^ *c.Mask; outside != 0 {
+			reason := fmt.Sprintf("bits 0x%X outside mask 0x%X", outside, *c.Mask)
+			if !c.Clamp {
+				return words, reason, false
+			}
+			raw &= *c.Mask
+			words = []uint16{uint16(raw)}
+			if c.Words() == 2 {
+				words = []uint16{uint16(raw >> 16), uint16(raw)}
+			}
+			reasons = append(reasons, reason+" cleared")
+		}
+	}
+
+	v := decodeValue(c, words)
+	// Enum values are compared in the precision of the type, e.g. 0.1 as float32
+	inEnum := slices.ContainsFunc(c.Enum, func(e float64) bool { return decodeValue(c, encodeValue(c, e)) == v })
+	if len(c.Enum) > 0 && !inEnum {
+		return words, fmt.Sprintf("value %s is not one of %s", formatValue(v), formatValues(c.Enum)), false
+	}
+	if math.IsNaN(v) && (c.Min != nil || c.Max != nil) {
+		return words, "value NaN is out of range", false
+	}
+	limit, reason := v, ""
+	if c.Min != nil && v < *c.Min {
+		limit, reason = *c.Min, fmt.Sprintf("value %s below min %s", formatValue(v), formatValue(*c.Min))
+	}
+	if c.Max != nil && v > *c.Max {
+		limit, reason = *c.Max, fmt.Sprintf("value %s above max %s", formatValue(v), formatValue(*c.Max))
+	}
+	if reason != "" {
+		if !c.Clamp {
+			return words, reason, false
+		}
+		words = encodeValue(c, limit)
+		reasons = append(reasons, fmt.Sprintf("%s clamped to %s", reason, formatValue(limit)))
+	}
+	return words, strings.Join(reasons, ", "), true
+}
+
+// decodeValue returns the value held by words according to the type of c.
+func decodeValue(c config.Constraint, words []uint16) float64 {
+	switch c.DataType() {
+	case "int16":
+		return float64(int16(words[0]))
+	case "int32":
+		return float64(int32(uint32(words[0])<<16 | uint32(words[1])))
+	case "float32":
+		return float64(encoding.RegistersToFloat32(words[0], words[1]))
+	}
+	return float64(words[0])
+}
+
+// encodeValue returns the words holding v according to the type of c.
+func encodeValue(c config.Constraint, v float64) []uint16 {
+	switch c.DataType() {
+	case "int16":
+		return []uint16{uint16(int16(v))}
+	case "int32":
+		i := uint32(int32(v))
+		return []uint16{uint16(i >> 16), uint16(i)}
+	case "float32":
+		high, low := encoding.Float32ToRegisters(float32(v))
+		return []uint16{high, low}
+	}
+	return []uint16{uint16(v)}
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatValues(vs []float64) string {
+	var s []string
+	for _, v := range vs {
+		s = append(s, formatValue(v))
+	}
+	return strings.Join(s, ", ")
+}
+
+// constraintStatus describes the value constraints of the register map.
+func (s *Slave) constraintStatus() string {
+	var status string
+	for _, c := range s.registerMap.Constraints {
+		var limits []string
+		if c.Min != nil {
+			limits = append(limits, "min "+formatValue(*c.Min))
+		}
+		if c.Max != nil {
+			limits = append(limits, "max "+formatValue(*c.Max))
+		}
+		if len(c.Enum) > 0 {
+			limits = append(limits, "one of "+formatValues(c.Enum))
+		}
+		if c.Mask != nil {
+			limits = append(limits, fmt.Sprintf("mask 0x%X", *c.Mask))
+		}
+		if c.Clamp {
+			limits = append(limits, "clamped")
+		}
+		status += fmt.Sprintf("\n    - Holding register 0x%04X (%s): %s", c.Register, c.DataType(), strings.Join(limits, ", "))
+	}
+	return status
+}
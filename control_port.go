@@ -1,5 +1,7 @@
 package modbuslabs
 
+import "time"
+
 type ControlPort interface {
 	ConnectSlave(unitID uint8, url string) error
 	DisconnectSlave(unitID uint8)
@@ -7,5 +9,18 @@ type ControlPort interface {
 
 	// WriteRegister writes one or more uint16 values to consecutive
 	// registers on the slave identified by unitID, starting at addr.
+	// It bypasses the access ranges of the slave's register map.
 	WriteRegister(unitID uint8, addr uint16, values []uint16) error
+
+	// SetFaults switches fault injection on or off for the transport at
+	// url, or for all transports if url is empty.
+	SetFaults(url string, enabled bool) error
+
+	// SetNetwork switches the network emulation on or off for the transport
+	// at url, or for all transports if url is empty.
+	SetNetwork(url string, enabled bool) error
+
+	// SetBusy lets the slave identified by unitID answer the function codes
+	// fcs, or all if fcs is empty, with exception 06 for the duration d.
+	SetBusy(unitID uint8, d time.Duration, fcs []uint8) error
 }
@@ -0,0 +1,167 @@
+// Package fault makes transports misbehave on purpose, e.g. drop or corrupt
+// responses, to test how robust masters are.
+package fault
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Fault types
+const (
+	Drop               = "drop"                 // don't send the response
+	Delay              = "delay"                // send the response after a random delay
+	CorruptCRC         = "corrupt_crc"          // RTU only: send an invalid CRC
+	FlipBits           = "flip_bits"            // flip a random bit of the response payload
+	Truncate           = "truncate"             // cut the response frame at a random length
+	WrongTransactionID = "wrong_transaction_id" // TCP only: answer with another transaction ID
+	Duplicate          = "duplicate"            // send the response twice
+)
+
+// Fault is a fault injected into a response with the given probability.
+type Fault struct {
+	Type        string
+	Probability float64       // 0 to 1
+	MinDelay    time.Duration // delay only
+	MaxDelay    time.Duration // delay only
+}
+
+func (f Fault) String() string {
+	s := fmt.Sprintf("%s %g%%", f.Type, f.Probability*100)
+	if f.Type == Delay {
+		s += fmt.Sprintf(" %s-%s", f.MinDelay, f.MaxDelay)
+	}
+	return s
+}
+
+// Injector draws the faults of the responses sent by one transport. Faults
+// of the transport apply to every response, faults of a slave to the
+// responses of this slave only. It is safe for concurrent use.
+type Injector struct {
+	enabled   atomic.Bool
+	transport []Fault
+	slaves    map[uint8][]Fault
+}
+
+// NewInjector creates an enabled injector.
+func NewInjector(transport []Fault, slaves map[uint8][]Fault) *Injector {
+	i := &Injector{transport: transport, slaves: slaves}
+	i.enabled.Store(true)
+	return i
+}
+
+// SetEnabled switches fault injection on or off.
+func (i *Injector) SetEnabled(enabled bool) {
+	i.enabled.Store(enabled)
+}
+
+// Draw returns the faults to inject into the next response of the slave with
+// unitID. A nil or disabled injector never injects faults.
+func (i *Injector) Draw(unitID uint8) Plan {
+	var p Plan
+	if i == nil || !i.enabled.Load() {
+		return p
+	}
+	for _, f := range slices.Concat(i.transport, i.slaves[unitID]) {
+		if rand.Float64() >= f.Probability {
+			continue
+		}
+		switch f.Type {
+		case Drop:
+			p.Drop = true
+		case Delay:
+			p.Delay += f.MinDelay + rand.N(f.MaxDelay-f.MinDelay+1)
+		case CorruptCRC:
+			p.CorruptCRC = true
+		case FlipBits:
+			p.FlipBits = true
+		case Truncate:
+			p.Truncate = true
+		case WrongTransactionID:
+			p.WrongTransactionID = true
+		case Duplicate:
+			p.Duplicate = true
+		}
+	}
+	return p
+}
+
+// Status describes the configured faults and whether they are injected.
+func (i *Injector) Status() string {
+	state := "on"
+	if !i.enabled.Load() {
+		state = "off"
+	}
+	var faults []string
+	if len(i.transport) > 0 {
+		faults = append(faults, join(i.transport)+" for all units")
+	}
+	for _, unitID := range slices.Sorted(maps.Keys(i.slaves)) {
+		faults = append(faults, fmt.Sprintf("%s for unit %d", join(i.slaves[unitID]), unitID))
+	}
+	return fmt.Sprintf("\n  Faults (%s): %s", state, strings.Join(faults, "; "))
+}
+
+func join(faults []Fault) string {
+	var s []string
+	for _, f := range faults {
+		s = append(s, f.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+// Plan is the set of faults drawn for one response.
+type Plan struct {
+	Drop               bool
+	Delay              time.Duration
+	CorruptCRC         bool
+	FlipBits           bool
+	Truncate           bool
+	WrongTransactionID bool
+	Duplicate          bool
+}
+
+// Empty reports whether no fault is injected.
+func (p Plan) Empty() bool {
+	return p == Plan{}
+}
+
+func (p Plan) String() string {
+	var s []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{Drop, p.Drop}, {fmt.Sprintf("%s %s", Delay, p.Delay.Round(time.Millisecond)), p.Delay > 0},
+		{CorruptCRC, p.CorruptCRC}, {FlipBits, p.FlipBits}, {Truncate, p.Truncate},
+		{WrongTransactionID, p.WrongTransactionID}, {Duplicate, p.Duplicate},
+	} {
+		if f.set {
+			s = append(s, f.name)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// FlipBit inverts one random bit of b.
+func FlipBit(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	i := rand.IntN(len(b) * 8)
+	b[i/8] ^= 1 << (i % 8)
+}
+
+// TruncateFrame returns frame cut to a random length of at least one byte
+// and less than its length.
+func TruncateFrame(frame []byte) []byte {
+	if len(frame) < 2 {
+		return frame
+	}
+	return frame[:1+rand.IntN(len(frame)-1)]
+}
@@ -0,0 +1,26 @@
+Feature: Auto-provisioned Slaves
+  Promiscuous transports create a slave from a named template on the first
+  request to an unknown unit ID.
+
+  Scenario: Unknown unit ID creates a slave from the template
+    Given template "meter" with registers 0x0010-0x0011 = 230, 50
+    And transport "localhost:5020" with promiscuous = "meter"
+    When a master reads 2 input registers at 0x0010 of unit 12
+    Then the master receives 230 and 50
+    And "slave 12 created on localhost:5020 from template \"meter\"" is logged
+
+  Scenario: Created slaves have the template's rules
+    Given template "meter" with a write_register rule for register 0x0020
+    When a master writes 1 to register 0x0020 of unit 12
+    Then register 0x0021 of slave 12 is set by the rule
+    And slave 13 created later is not affected
+
+  Scenario: Disconnected slaves are not recreated
+    Given slave 12 was created and is disconnected with "d 12"
+    When a master reads from unit 12
+    Then "slave 12 does not exist or is offline" is logged
+
+  Scenario: Unknown template
+    Given transport with promiscuous = "pump" and no template "pump"
+    When slavesim loads the configuration
+    Then it fails with "promiscuous template \"pump\" is not defined"
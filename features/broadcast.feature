@@ -0,0 +1,28 @@
+Feature: Broadcast
+  Write requests to unit ID 0 are applied to all slaves of a transport and
+  not answered. Broadcasts are enabled on RTU transports by default and
+  optionally on TCP transports.
+
+  Scenario: Broadcast write on RTU
+    Given slaves 4 and 5 on RTU transport "/tmp/ttyF0"
+    When a master writes 1 and 2 to registers 0x0020-0x0021 of unit 0
+    Then the master receives no response
+    And registers 0x0020-0x0021 of slaves 4 and 5 are 1 and 2
+    And "BROADCAST FC=16 ... applied to units [4 5]" is logged
+
+  Scenario: Broadcast write on TCP with broadcasts enabled
+    Given transport "localhost:5020" has broadcast = true
+    When a master writes 7 to register 0x0010 of unit 0
+    Then register 0x0010 of slaves 1 and 2 is 7
+    And slaves on other transports are unchanged
+
+  Scenario: Broadcasts disabled
+    Given transport "localhost:5022" without broadcast setting
+    When a master writes to unit 0
+    Then no slave is changed
+    And "broadcasts are disabled on localhost:5022" is logged
+
+  Scenario: Read requests are not broadcast
+    When a master reads input registers of unit 0 on an RTU transport
+    Then no response is sent
+    And "only write requests may be broadcast" is logged
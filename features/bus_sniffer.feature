@@ -0,0 +1,22 @@
+Feature: Bus Sniffer
+  The sniffer sits between a master and a slave on two virtual serial ports,
+  relays their traffic and decodes every RTU frame.
+
+  Background:
+    Given the sniffer runs with master port "/tmp/ttyM" and slave port "/tmp/ttyS"
+    And a slave with unit ID 3 is connected to "/tmp/ttyS"
+
+  Scenario: Requests and responses are relayed and decoded
+    When a master connected to "/tmp/ttyM" reads 3 input registers from 0x0010 of unit 3
+    Then the master receives the slave's response
+    And the protocol shows "REQ FC=4 UnitID=3 Address=0x10 Quantity=3 CRC=ok"
+    And the protocol shows the values read with their addresses
+    And the protocol shows the response latency
+
+  Scenario: Corrupted frames are reported
+    When the slave answers with a wrong CRC
+    Then the protocol shows the response with "CRC=invalid"
+
+  Scenario: Ports are removed when the sniffer stops
+    When the sniffer is stopped
+    Then "/tmp/ttyM" and "/tmp/ttyS" no longer exist
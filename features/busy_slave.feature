@@ -0,0 +1,27 @@
+Feature: Busy Slaves
+  Slaves answer exception 06 (slave device busy) during busy windows started
+  by rules or the console, and when polled too fast. The request starting a
+  long-running operation may be acknowledged with exception 05.
+
+  Scenario: Busy window started by a write
+    Given slave 1 has a busy rule on writing 1 to register 0x0100 for "1s" with function_codes [4] and acknowledge
+    When a master writes 1 to register 0x0100 of slave 1
+    Then the master receives exception 05
+    When the master reads input registers of slave 1 within 1s
+    Then the master receives exception 06
+    When the master writes register 0x0101 of slave 1
+    Then the write succeeds
+    When 1s has passed
+    Then reading input registers of slave 1 succeeds
+
+  Scenario: Busy window started from the console
+    When "b 2 1s" is entered
+    Then every request to slave 2 is answered with exception 06 for 1s
+    And the status shows "Busy until"
+
+  Scenario: Polled too fast
+    Given slave 2 has min_poll_interval "300ms"
+    When a master reads slave 2 twice within 300ms
+    Then the second request is answered with exception 06
+    When the master waits 400ms and reads again
+    Then the request succeeds
@@ -0,0 +1,29 @@
+Feature: Connectivity Schedules
+  Slaves are connected and disconnected automatically according to a
+  schedule, so that masters can be tested against flapping connections.
+
+  Scenario: Periodic outages
+    Given slave 1 has a periodic schedule with online "1s" and offline "500ms"
+    When slavesim has been running for 1.2s
+    Then slave 1 is disconnected
+    And "SCHEDULE slave 1 on localhost:5020 disconnected for 500ms" is logged
+    When another 500ms have passed
+    Then slave 1 is connected again
+
+  Scenario: Random outages
+    Given slave 2 has a random schedule with online "800ms" and offline "300ms"
+    When slavesim runs for a while
+    Then slave 2 is disconnected and connected at random times
+    And each change is logged with the duration of the new state
+
+  Scenario: Explicit events
+    Given slave 3 has events "after 700ms disconnected" and "after 1200ms connected"
+    When slavesim has been running for 1s
+    Then slave 3 is disconnected
+    When slavesim has been running for 1.3s
+    Then slave 3 is connected
+
+  Scenario: Invalid schedule
+    Given a periodic schedule without offline duration
+    When slavesim loads the configuration
+    Then it fails with "requires positive online and offline durations"
@@ -0,0 +1,35 @@
+Feature: Fault Injection
+  slavesim injects faults into responses with a configured probability per
+  transport or per slave, so that masters can be tested for robustness.
+
+  Scenario: Delayed response
+    Given slave 1 on "localhost:5020" has a delay fault with probability 1 between 100ms and 200ms
+    When a master reads from slave 1
+    Then the response arrives after 100ms to 200ms
+    And "FAULT delay" is logged for unit 1
+
+  Scenario: Wrong transaction ID
+    Given transport "localhost:5020" has a wrong_transaction_id fault with probability 1
+    When a master reads from slave 1
+    Then the response carries the request's transaction ID plus one
+
+  Scenario: Corrupted CRC
+    Given slave 2 on an RTU transport has a corrupt_crc fault with probability 1
+    When a master writes to slave 2
+    Then the response has an invalid CRC
+
+  Scenario: Duplicate response
+    Given slave 2 has a duplicate fault with probability 1
+    When a master writes to slave 2
+    Then the response is sent twice
+
+  Scenario: Fault injection switched off from the console
+    Given faults are configured for transport "localhost:5020"
+    When "f off" is entered
+    Then responses are sent without faults
+    And the status shows "Faults (off)"
+
+  Scenario: Invalid fault configuration
+    Given a corrupt_crc fault on a tcp transport
+    When slavesim loads the configuration
+    Then it fails with "corrupt_crc requires an rtu transport"
@@ -0,0 +1,25 @@
+Feature: Learn Mode
+  slavesim and the sniffer observe the traffic with a real device and write a
+  slave configuration with the observed register values, so that the device
+  can be simulated later.
+
+  Scenario: Proxy slave learns values read and written upstream
+    Given slavesim runs with config "proxy.toml" and "-learn learned.toml"
+    When a master writes 5 to register 0x0002 of proxy slave 1
+    And the master writes 0x1234 0x5678 to registers 0x0003-0x0004
+    And slavesim is stopped
+    Then "learned.toml" contains a slave 1 on "localhost:5020"
+    And a register range at 0x0002 with values 0x0005, 0x1234, 0x5678
+
+  Scenario: Learned configuration simulates the device
+    Given slavesim runs with the slave section of "learned.toml"
+    When a master reads 3 input registers at 0x0002 of slave 1
+    Then the values 5, 4660 and 22136 are returned
+
+  Scenario: Sniffer learns from valid frames only
+    Given the sniffer runs with "-learn learned.toml"
+    When the slave answers a read of 3 input registers at 0x0010
+    And the slave answers another request with an invalid CRC
+    And the sniffer is stopped
+    Then "learned.toml" contains the 3 registers at 0x0010
+    And nothing of the response with the invalid CRC
@@ -0,0 +1,24 @@
+Feature: Modbus/TCP Security
+  TLS transports require client certificates signed by a configured CA. The
+  role from the certificate's Modbus role extension restricts writes via
+  require_role rules.
+
+  Background:
+    Given a tls transport on "localhost:802" with a CA, server certificate and key
+    And slave 1 only allows the role "engineer" to write register 0xA66D
+
+  Scenario: Authorized write
+    When a master with a certificate for role "engineer" writes register 0xA66D of slave 1
+    Then the write succeeds
+
+  Scenario: Unauthorized write
+    When a master with a certificate for role "operator" writes register 0xA66D of slave 1
+    Then the master receives exception 01
+
+  Scenario: Certificate without role
+    When a master with a certificate without role extension writes register 0xA66D of slave 1
+    Then the master receives exception 01
+
+  Scenario: Missing client certificate
+    When a master without client certificate connects
+    Then the TLS handshake fails
@@ -0,0 +1,33 @@
+Feature: Native Pseudo-Terminal Pair
+  When slavesim is configured with an RTU transport, it creates a virtual
+  serial port pair from two pseudo-terminals without external tools. The
+  pair is created when the gateway starts and removed when it stops.
+
+  Scenario: Pair is created when RTU transport is configured
+    Given slavesim is configured with RTU transport at "/tmp/ttyV0" and peer "/tmp/ttyV1"
+    When slavesim starts
+    Then "/tmp/ttyV0" is a symlink to a pseudo-terminal
+    And "/tmp/ttyV1" is a symlink to a pseudo-terminal
+    And no socat process is running
+
+  Scenario: Bytes are relayed between both sides
+    Given slavesim is running with RTU transport at "/tmp/ttyV0" and peer "/tmp/ttyV1"
+    When a master sends an RTU request to "/tmp/ttyV1"
+    Then the RTU handler receives the request on "/tmp/ttyV0"
+    And the master receives the response on "/tmp/ttyV1"
+
+  Scenario: Pair is removed when slavesim exits
+    Given slavesim is running with RTU transport at "/tmp/ttyV0" and peer "/tmp/ttyV1"
+    When slavesim exits
+    Then "/tmp/ttyV0" no longer exists
+    And "/tmp/ttyV1" no longer exists
+
+  Scenario: Stale links are replaced
+    Given "/tmp/ttyV0" is a dangling symlink left by a previous run
+    When slavesim starts with RTU transport at "/tmp/ttyV0"
+    Then "/tmp/ttyV0" is a symlink to a new pseudo-terminal
+
+  Scenario: Socat is used on request
+    Given slavesim is configured with RTU transport with pty "socat"
+    When slavesim starts
+    Then socat is running
@@ -0,0 +1,27 @@
+Feature: Network Emulation
+  TCP transports emulate bad network links for their responses, switchable
+  at runtime from the console.
+
+  Scenario: Latency and byte by byte responses
+    Given transport "localhost:5020" has latency "200ms", jitter "50ms" and byte_pause "20ms"
+    When a master reads one input register of slave 1
+    Then the response arrives after 150ms at the earliest
+    And its bytes arrive one at a time
+
+  Scenario: TCP reset
+    Given transport "localhost:5022" has reset 1
+    When a master reads from slave 2
+    Then the master's connection is reset by peer
+    And "NET connection from ... reset after" is logged
+
+  Scenario: Half-open connection
+    Given transport "localhost:5023" has half_open 1
+    When a master reads from slave 3
+    Then the request is processed but the master times out
+    And the status marks the connection as half-open
+
+  Scenario: Emulation switched off
+    Given transport "localhost:5020" has latency "200ms"
+    When "n off localhost:5020" is entered
+    Then responses of "localhost:5020" are sent without delay
+    And the status shows "Network (off)"
@@ -0,0 +1,33 @@
+Feature: Proxy Slave
+  A proxy slave forwards requests to an upstream slave, e.g. a real device
+  or a second slavesim, and returns its responses. Registers within a local
+  range are served by slavesim itself. Registers are written with FC6 and
+  read back with FC4.
+
+  Background:
+    Given an upstream slavesim serves slave 7 on "localhost:5021"
+    And slavesim serves proxy slave 1 on "localhost:5020" with upstream "tcp://localhost:5021" unit 7
+    And the proxy slave serves registers 0x0010 to 0x0013 locally
+
+  Scenario: Requests are forwarded upstream
+    When a master writes 42 to register 0x0002 of slave 1 on "localhost:5020" with FC6
+    Then the master reads 42 from register 0x0002 of slave 7 on "localhost:5021" with FC4
+    And the master reads 42 from register 0x0002 of slave 1 on "localhost:5020" with FC4
+    And the protocol shows "UP TX" and "UP RX" lines for both requests
+
+  Scenario: Local registers are not forwarded
+    When a master writes 9 to register 0x0011 of slave 1 on "localhost:5020" with FC6
+    Then the master reads 9 from register 0x0011 of slave 1 on "localhost:5020" with FC4
+    And the master reads 0 from register 0x0011 of slave 7 on "localhost:5021" with FC4
+
+  Scenario: Requests spanning local and upstream registers are forwarded
+    Given the master wrote 9 to register 0x0010 of slave 1 on "localhost:5020" with FC6
+    And the master wrote 1 and 2 to registers 0x000F and 0x0010 of slave 7 on "localhost:5021" with FC6
+    When the master reads 2 registers from 0x000F of slave 1 on "localhost:5020" with FC4
+    Then the master receives the upstream values 1 and 2
+
+  Scenario: Upstream doesn't answer
+    Given the upstream slavesim is stopped
+    When a master reads register 0x0002 of slave 1 on "localhost:5020" with FC4
+    Then the master receives no response
+    And the protocol shows why forwarding failed
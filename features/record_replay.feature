@@ -0,0 +1,30 @@
+Feature: Record and Replay
+  slavesim records the requests of a master together with their responses.
+  The replay command sends the recorded requests again and reports responses
+  that differ from the recorded ones.
+
+  Scenario: Session is recorded
+    Given slavesim runs with "-record session.jsonl"
+    When a master writes 5 to register 0x0002 of slave 1
+    And the master reads register 0x0002 of slave 1
+    Then "session.jsonl" contains both requests with their responses and arrival offsets
+
+  Scenario: Unanswered requests are recorded without response
+    Given slavesim runs with "-record session.jsonl"
+    When a master reads from slave 9, which doesn't exist
+    Then "session.jsonl" contains the request with response null
+
+  Scenario: Replay with matching responses
+    Given a session recorded against slavesim with config "rec.toml"
+    And a fresh slavesim runs with config "rec.toml"
+    When the session is replayed
+    Then the requests are sent with the recorded timing
+    And "0 responses differ" is reported
+    And replay exits with status 0
+
+  Scenario: Replay with differing responses
+    Given a session recorded against slavesim
+    And the simulated register values have changed since
+    When the session is replayed with "-fast"
+    Then each differing response is reported with the expected and the received payload
+    And replay exits with status 2
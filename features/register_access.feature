@@ -0,0 +1,33 @@
+Feature: Register Access Permissions
+  Ranges of holding registers and coils are read-only, write-only,
+  write-once or locked until an unlock sequence is written. Denied accesses
+  are answered with an exception, console writes bypass the permissions.
+
+  Scenario: Read-only registers
+    Given slave 1 declares holding registers 0x0000-0x0001 read_only
+    When a master writes register 0x0001 of slave 1
+    Then the master receives exception 02
+    When "w 1 0x0001 9" is entered
+    Then register 0x0001 of slave 1 is 9
+
+  Scenario: Write-only registers
+    Given slave 1 declares holding register 0x0010 write_only
+    When a master reads register 0x0010 of slave 1
+    Then the master receives exception 02
+    When the master writes register 0x0010 of slave 1
+    Then the write succeeds
+
+  Scenario: Write-once registers
+    Given slave 1 declares holding registers 0x0020-0x0021 write_once
+    When a master writes register 0x0020 of slave 1
+    Then the write succeeds
+    When the master writes register 0x0020 of slave 1 again
+    Then the master receives exception 02
+
+  Scenario: Unlock sequence
+    Given slave 1 declares holding registers 0x0030-0x0033 locked by the sequence 0x1234, 0x5678 on register 0x00FF with exception 01
+    When a master writes register 0x0031 of slave 1
+    Then the master receives exception 01
+    When the master writes 0x1234 and then 0x5678 to register 0x00FF of slave 1
+    Then the log shows "holding register 0x0030-0x0033 unlocked"
+    And writing register 0x0031 of slave 1 succeeds
@@ -0,0 +1,26 @@
+Feature: Register Maps
+  Slaves declare their valid addresses per data table. Requests outside the
+  declared ranges or spanning a gap are answered with exception 02.
+
+  Background:
+    Given slave 1 declares holding registers 0x0000-0x0009 and 0x0100-0x0103
+
+  Scenario: Access within a declared range
+    When a master writes 10 holding registers from 0x0000 of slave 1
+    Then the write succeeds
+
+  Scenario: Access outside the declared ranges
+    When a master writes register 0x0050 of slave 1
+    Then the master receives exception 02
+
+  Scenario: Access spanning a gap
+    When a master writes 4 holding registers from 0x0008 of slave 1
+    Then the master receives exception 02
+
+  Scenario: Undeclared tables are unrestricted
+    When a master reads input register 0x1234 of slave 1
+    Then the read succeeds
+
+  Scenario: Status shows the map
+    When "s" is entered
+    Then the status shows "Holding registers: 0x0000-0x0009, 0x0100-0x0103"
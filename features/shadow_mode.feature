@@ -0,0 +1,27 @@
+Feature: Shadow Mode
+  A shadowed slave answers requests locally and sends them to a reference
+  slave as well. Responses of the reference that differ are reported.
+
+  Scenario: Differing payload is reported
+    Given slave 1 is shadowed by reference slave 7 on "tcp://localhost:5021"
+    And register 0x0002 is 5 locally and 0 on the reference
+    When a master reads 2 input registers at 0x0002 of slave 1
+    Then the master receives the local values
+    And "SHADOW FC=4 UnitID=1" reports the local and the reference payload
+
+  Scenario: Matching responses are not reported
+    Given slave 1 is shadowed by a reference with the same register values
+    When a master writes 9 to register 0x0003 of slave 1
+    Then no difference is reported
+
+  Scenario: Slow reference is reported
+    Given slave 1 is shadowed with timing_threshold "5ms"
+    And the reference answers after 20ms
+    When a master reads from slave 1
+    Then a timing difference is reported
+    And the local response is not delayed
+
+  Scenario: Comparison results in status
+    Given one of two responses of shadowed slave 1 differed
+    When the status is printed
+    Then it shows "Shadow: tcp://localhost:5021 unit 7, 1 of 2 responses differ"
@@ -1,16 +1,17 @@
 Feature: Start Socat
-  When slavesim is configured with an RTU transport, it automatically
-  starts socat to create a virtual serial port pair. When slavesim
-  exits, socat is stopped automatically.
+  When slavesim is configured with an RTU transport with pty "socat", or
+  native pseudo-terminals are not supported on the platform, it
+  automatically starts socat to create a virtual serial port pair. When
+  slavesim exits, socat is stopped automatically.
 
   Scenario: Socat starts when RTU transport is configured
-    Given slavesim is configured with RTU transport at "/tmp/ttyV0"
+    Given slavesim is configured with socat RTU transport at "/tmp/ttyV0"
     When slavesim starts
     Then socat is running
     And the virtual TTY "/tmp/ttyV0" exists
 
   Scenario: Socat stops when slavesim exits
-    Given slavesim is running with RTU transport at "/tmp/ttyV0"
+    Given slavesim is running with socat RTU transport at "/tmp/ttyV0"
     When slavesim exits
     Then socat is no longer running
     And the virtual TTY "/tmp/ttyV0" no longer exists
@@ -22,13 +23,26 @@ Feature: Start Socat
 
   Scenario: Error when socat is not installed
     Given socat is not installed on the system
-    And slavesim is configured with RTU transport
+    And slavesim is configured with socat RTU transport
     When slavesim starts
     Then an error message "socat not found: install socat first" is shown
     And slavesim exits with a non-zero status
 
   Scenario: Error when virtual TTY is not created in time
-    Given slavesim is configured with RTU transport at "/tmp/ttyV0"
-    When socat starts but the virtual TTY is not created within 100ms
+    Given slavesim is configured with socat RTU transport at "/tmp/ttyV0"
+    When socat starts but the virtual TTY is not created within 1s
     Then an error message "/tmp/ttyV0 doesn't exist" is shown
     And slavesim exits with a non-zero status
+
+  Scenario: Crashed socat is restarted
+    Given slavesim is running with socat RTU transport at "/tmp/ttyV0"
+    When the socat process terminates unexpectedly
+    Then the terminated process is reaped
+    And socat is restarted after a backoff delay
+    And the RTU handler reopens "/tmp/ttyV0"
+    And the status shows the number of restarts
+
+  Scenario: Socat diagnostics are logged
+    Given slavesim is running with socat RTU transport at "/tmp/ttyV0"
+    When socat writes a message to stderr
+    Then the message is shown in the protocol output
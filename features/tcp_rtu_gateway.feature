@@ -0,0 +1,26 @@
+Feature: TCP-to-RTU Gateway
+  slavesim routes requests for configured unit IDs from a TCP transport to a
+  serial bus and acts as master on the bus.
+
+  Background:
+    Given a second slavesim serves slave 10 on RTU transport "/tmp/ttyV0" with peer "/tmp/ttyV1"
+    And slavesim routes unit IDs 10 and 11 on "localhost:5020" to bus "rtu:///tmp/ttyV1"
+
+  Scenario: Requests are routed to the bus
+    When a master reads register 0x0002 of unit 10 on "localhost:5020"
+    Then the request is sent to slave 10 on the bus
+    And the master receives the bus slave's response
+
+  Scenario: Bus slave doesn't answer
+    When a master reads register 0x0002 of unit 11 on "localhost:5020"
+    Then the master receives exception 0x0B after the bus timeout
+
+  Scenario: Bus is unavailable
+    Given the serial port of the bus can't be opened
+    When a master reads register 0x0002 of unit 10 on "localhost:5020"
+    Then the master receives exception 0x0A
+
+  Scenario: Concurrent requests share the bus
+    When several masters read from unit 10 at the same time
+    Then the requests are sent to the bus one at a time
+    And every master receives its response
@@ -0,0 +1,23 @@
+Feature: Unit ID Routing
+  TCP transports emulate single-device servers that ignore the unit ID or
+  expect 0 or 255, by answering requests with a designated slave.
+
+  Scenario: Any unit ID
+    Given transport "localhost:5020" with unit_id_routing "any" and designated_slave 1
+    When a master writes 7 to register 0x0010 of unit 77
+    Then register 0x0010 of slave 1 is 7
+    And the response carries unit ID 77
+
+  Scenario: Unit IDs 0 and 255 only
+    Given transport "localhost:5022" with unit_id_routing "0_255" and designated_slave 3
+    When a master reads from unit 255
+    Then slave 3 answers with unit ID 255
+    When a master reads from unit 4
+    Then slave 4 answers
+    When a master reads from unit 9
+    Then no response is sent
+
+  Scenario: Designated slave must exist
+    Given unit_id_routing "any" with designated_slave 2 that is not configured on the transport
+    When slavesim loads the configuration
+    Then it fails with "designated_slave 2 is not a slave of this transport"
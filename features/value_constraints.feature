@@ -0,0 +1,27 @@
+Feature: Value Constraints
+  Holding registers declare min/max ranges, enumerations or bit masks for
+  raw, int32 or float32 values. Violating writes are answered with
+  exception 03 or clamped.
+
+  Scenario: Value above max
+    Given slave 1 constrains holding register 0x0040 to min 0 and max 100
+    When a master writes 150 to register 0x0040 of slave 1
+    Then the master receives exception 03
+    When the master writes 50 to register 0x0040 of slave 1
+    Then the write succeeds
+
+  Scenario: Value outside enum
+    Given slave 1 constrains holding register 0x0041 to one of 1, 2, 4
+    When a master writes 3 to register 0x0041 of slave 1
+    Then the master receives exception 03
+
+  Scenario: Clamped float32 value
+    Given slave 1 constrains the float32 at holding register 0x0050 to max 50.0 with clamp
+    When a master writes the float32 100.0 to registers 0x0050-0x0051 of slave 1
+    Then the write succeeds
+    And the float32 at register 0x0050 of slave 1 is 50.0
+
+  Scenario: Bits outside mask
+    Given slave 1 constrains holding register 0x0042 to mask 0x00FF with clamp
+    When a master writes 0x1234 to register 0x0042 of slave 1
+    Then register 0x0042 of slave 1 is 0x0034
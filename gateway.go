@@ -2,31 +2,45 @@ package modbuslabs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/rwirdemann/modbuslabs/config"
 	"github.com/rwirdemann/modbuslabs/encoding"
 	"github.com/rwirdemann/modbuslabs/message"
 	"github.com/rwirdemann/modbuslabs/rules"
+	"github.com/rwirdemann/modbuslabs/session"
 )
 
 // Gateway represents a gateway with modbus devices.
 type Gateway struct {
-	handler      []TransportHandler
-	protocolPort ProtocolPort
-	slaves       map[string]map[uint8]*Slave // map[url]map[unitID]slave
-	slaveLock    *sync.Mutex
+	handler        []TransportHandler
+	protocolPort   ProtocolPort
+	slaves         map[string]map[uint8]*Slave // map[url]map[unitID]slave
+	slaveLock      *sync.Mutex
+	routes         map[string]map[uint8]Upstream // map[url]map[unitID]bus, see AddRoute
+	recorder       *session.Writer               // nil unless the session is recorded
+	broadcasts     map[string]bool               // map[url]enabled, see SetBroadcast
+	templates      map[string]config.Template    // map[url]template, see SetPromiscuous
+	unitIDRoutings map[string]unitIDRouting      // map[url]routing, see SetUnitIDRouting
 }
 
 // NewGateway creates a new gateway.
 func NewGateway(handler []TransportHandler, protocolPort ProtocolPort) *Gateway {
 	b := &Gateway{
-		handler:      handler,
-		protocolPort: protocolPort,
-		slaves:       make(map[string]map[uint8]*Slave),
-		slaveLock:    new(sync.Mutex),
+		handler:        handler,
+		protocolPort:   protocolPort,
+		slaves:         make(map[string]map[uint8]*Slave),
+		slaveLock:      new(sync.Mutex),
+		routes:         make(map[string]map[uint8]Upstream),
+		broadcasts:     make(map[string]bool),
+		templates:      make(map[string]config.Template),
+		unitIDRoutings: make(map[string]unitIDRouting),
 	}
 	for _, h := range b.handler {
 		b.slaves[h.Description()] = make(map[uint8]*Slave)
@@ -34,21 +48,98 @@ func NewGateway(handler []TransportHandler, protocolPort ProtocolPort) *Gateway
 	return b
 }
 
-// Start starts the gateway.
+// Start starts the gateway. If a transport handler fails to start, the
+// handlers already started are stopped again.
 func (m *Gateway) Start(ctx context.Context) error {
-	for _, h := range m.handler {
-		if err := h.Start(ctx, m.processPDU); err != nil {
+	for i, h := range m.handler {
+		url := h.Description()
+		processPDU := func(pdu PDU) *PDU { return m.processPDU(url, pdu) }
+		processPDU = m.routeUnitID(url, processPDU)
+		if m.recorder != nil {
+			processPDU = m.record(url, processPDU)
+		}
+		if err := h.Start(ctx, processPDU); err != nil {
+			for _, started := range m.handler[:i] {
+				_ = started.Stop()
+			}
 			return err
 		}
 	}
 	return nil
 }
 
+// Record makes the gateway write every request and its response to w. It
+// must be called before the gateway is started.
+func (m *Gateway) Record(w *session.Writer) {
+	m.recorder = w
+}
+
+// record wraps processPDU of the transport at url, so that every exchange is
+// written to the session recorder.
+func (m *Gateway) record(url string, processPDU ProcessPDUCallback) ProcessPDUCallback {
+	return func(pdu PDU) *PDU {
+		received := time.Now()
+		res := processPDU(pdu)
+
+		e := session.Exchange{Transport: url, Request: session.Frame{UnitID: pdu.UnitId, FunctionCode: pdu.FunctionCode, Payload: pdu.Payload}}
+		if res != nil {
+			e.Response = &session.Frame{UnitID: res.UnitId, FunctionCode: res.FunctionCode, Payload: res.Payload}
+		}
+		if err := m.recorder.Write(received, e); err != nil {
+			slog.Debug("recording exchange failed", "err", err)
+		}
+		return res
+	}
+}
+
+// AddRoute makes the gateway forward requests for unitIDs received on the
+// transport at url to bus, acting as master on the bus. Routes must be added
+// before the gateway is started. Requests to the same bus are sent one at a
+// time by the bus itself.
+func (m *Gateway) AddRoute(url string, unitIDs []uint8, bus Upstream) {
+	if m.routes[url] == nil {
+		m.routes[url] = make(map[uint8]Upstream)
+	}
+	for _, unitID := range unitIDs {
+		m.routes[url][unitID] = bus
+	}
+}
+
+// SetBroadcast enables or disables broadcasts on the transport at url. Write
+// requests to unit ID 0 received on a transport with broadcasts enabled are
+// applied to all its slaves and not answered. It must be called before the
+// gateway is started.
+func (m *Gateway) SetBroadcast(url string, enabled bool) {
+	m.broadcasts[url] = enabled
+}
+
+// SetPromiscuous makes the transport at url create a slave from template on
+// the first request to an unknown unit ID. It must be called before the
+// gateway is started.
+func (m *Gateway) SetPromiscuous(url string, template config.Template) {
+	m.templates[url] = template
+}
+
 // Stop stops gateway.
 func (m *Gateway) Stop() error {
 	for _, h := range m.handler {
 		h.Stop()
 	}
+	for _, slaves := range m.slaves {
+		for _, s := range slaves {
+			if s.upstream != nil {
+				_ = s.upstream.Close()
+			}
+			if s.shadow != nil {
+				_ = s.shadow.close()
+			}
+		}
+	}
+	for _, buses := range m.routes {
+		for _, bus := range buses {
+			_ = bus.Close()
+		}
+	}
 	return nil
 }
 
@@ -64,15 +155,163 @@ func (b *Gateway) findSlave(unitID uint8) (*Slave, bool) {
 	return nil, false
 }
 
-func (h *Gateway) processPDU(pdu PDU) *PDU {
+// route forwards pdu to the serial bus and returns the bus slave's response.
+// Failures are answered with exception 0x0A if the bus is unavailable and
+// 0x0B if the slave doesn't answer in time.
+func (h *Gateway) route(bus Upstream, pdu PDU) *PDU {
+	h.protocolPort.Info(fmt.Sprintf("BUS TX %s % X", bus.Description(), append([]byte{pdu.UnitId, pdu.FunctionCode}, pdu.Payload...)))
+	res, err := bus.Send(pdu)
+	if err != nil {
+		code := ExGatewayTargetFailedToRespond
+		if errors.Is(err, ErrUpstreamUnavailable) {
+			code = ExGatewayPathUnavailable
+		}
+		h.protocolPort.Info(fmt.Sprintf("BUS %s failed: %s, answering exception 0x%02X", bus.Description(), err, code))
+		return NewExceptionPDU(pdu, code)
+	}
+	h.protocolPort.Info(fmt.Sprintf("BUS RX %s % X", bus.Description(), append([]byte{res.UnitId, res.FunctionCode}, res.Payload...)))
+	return res
+}
+
+// broadcast applies the write request pdu to all connected slaves of the
+// transport at url. Broadcasts are never answered.
+func (h *Gateway) broadcast(url string, pdu PDU) {
+	if !h.broadcasts[url] {
+		h.protocolPort.Info(fmt.Sprintf("BROADCAST FC=%d ignored, broadcasts are disabled on %s", pdu.FunctionCode, url))
+		return
+	}
+	switch pdu.FunctionCode {
+	case FC5WriteSingleCoil, FC6WriteSingleRegister, FC15WriteMultipleCoils, FC16WriteMultipleRegisters:
+	default:
+		h.protocolPort.Info(fmt.Sprintf("BROADCAST FC=%d ignored, only write requests may be broadcast", pdu.FunctionCode))
+		return
+	}
+
 	h.slaveLock.Lock()
-	defer h.slaveLock.Unlock()
-	slave, exists := h.findSlave(pdu.UnitId)
-	if !exists || !slave.connected {
+	var slaves []*Slave
+	for _, unitID := range slices.Sorted(maps.Keys(h.slaves[url])) {
+		if slave := h.slaves[url][unitID]; slave.connected {
+			slaves = append(slaves, slave)
+		}
+	}
+	h.slaveLock.Unlock()
+
+	var unitIDs []uint8
+	for _, slave := range slaves {
+		req := pdu
+		req.UnitId = slave.unitID
+		slave.lock.Lock()
+		res := h.answer(slave, req)
+		slave.lock.Unlock()
+		if res != nil && res.FunctionCode == pdu.FunctionCode {
+			unitIDs = append(unitIDs, slave.unitID)
+		}
+	}
+	h.protocolPort.Info(fmt.Sprintf("BROADCAST FC=%d Payload=% X applied to units %v", pdu.FunctionCode, pdu.Payload, unitIDs))
+}
+
+func (h *Gateway) processPDU(url string, pdu PDU) *PDU {
+	if pdu.UnitId == 0 {
+		h.broadcast(url, pdu)
+		return nil
+	}
+
+	// Routed requests don't touch local slaves and must not block them while
+	// waiting for the bus.
+	if bus, routed := h.routes[url][pdu.UnitId]; routed {
+		return h.route(bus, pdu)
+	}
+
+	slave, connected := h.connectedSlave(url, pdu.UnitId)
+	if !connected {
 		h.protocolPort.Info(fmt.Sprintf("slave %d does not exist or is offline", pdu.UnitId))
 		return nil
 	}
 
+	// Requests to different slaves are processed concurrently, e.g. pipelined
+	// requests or requests on different transports.
+	slave.lock.Lock()
+	defer slave.lock.Unlock()
+	if slave.shadow == nil {
+		return h.answer(slave, pdu)
+	}
+	started := time.Now()
+	res := h.answer(slave, pdu)
+	slave.shadow.compare(pdu, res, time.Since(started))
+	return res
+}
+
+// connectedSlave returns the slave with unitID, which is created from the
+// template of a promiscuous transport at url if it doesn't exist. It reports
+// false if the slave doesn't exist or is disconnected.
+func (h *Gateway) connectedSlave(url string, unitID uint8) (*Slave, bool) {
+	h.slaveLock.Lock()
+	defer h.slaveLock.Unlock()
+	slave, exists := h.findSlave(unitID)
+	if template, promiscuous := h.templates[url]; !exists && promiscuous {
+		h.connectSlaveWithConfig(template.Slave(unitID, url), url)
+		slave, exists = h.slaves[url][unitID], true
+		h.protocolPort.Info(fmt.Sprintf("slave %d created on %s from template %q", unitID, url, template.Name))
+	}
+	return slave, exists && slave.connected
+}
+
+// answer answers pdu by slave with exception 06 if the slave is busy, with
+// exception 02 if pdu addresses registers outside the slave's register map
+// and with an exception if the map's access ranges deny pdu. Values written
+// by pdu violating a constraint are answered with exception 03 or clamped.
+// Otherwise pdu is processed and may unlock access ranges or start a busy
+// window, in which case pdu is optionally acknowledged with exception 05.
+func (h *Gateway) answer(slave *Slave, pdu PDU) *PDU {
+	now := time.Now()
+	if reason, busy := slave.busy(pdu, now); busy {
+		h.protocolPort.Info(fmt.Sprintf("slave %d %s, answering exception 0x%02X", pdu.UnitId, reason, ExSlaveDeviceBusy))
+		return NewExceptionPDU(pdu, ExSlaveDeviceBusy)
+	}
+	if reason, illegal := slave.illegalAddress(pdu); illegal {
+		h.protocolPort.Info(fmt.Sprintf("slave %d: %s, answering exception 0x%02X", pdu.UnitId, reason, ExIllegalDataAddress))
+		return NewExceptionPDU(pdu, ExIllegalDataAddress)
+	}
+	if reason, exception, denied := slave.accessDenied(pdu, now); denied {
+		h.protocolPort.Info(fmt.Sprintf("slave %d: %s, answering exception 0x%02X", pdu.UnitId, reason, exception))
+		return NewExceptionPDU(pdu, exception)
+	}
+	pdu, notes, violated := slave.constrain(pdu)
+	if violated {
+		h.protocolPort.Info(fmt.Sprintf("slave %d: %s, answering exception 0x%02X", pdu.UnitId, notes[0], ExIllegalDataValue))
+		return NewExceptionPDU(pdu, ExIllegalDataValue)
+	}
+	for _, note := range notes {
+		h.protocolPort.Info(fmt.Sprintf("slave %d: %s", pdu.UnitId, note))
+	}
+
+	res := h.processSlave(slave, pdu)
+	if res == nil || res.FunctionCode != pdu.FunctionCode {
+		return res
+	}
+	for _, a := range slave.recordWrites(pdu, now) {
+		h.protocolPort.Info(fmt.Sprintf("slave %d: %s 0x%04X-0x%04X unlocked", pdu.UnitId, accessTables[a.DataTable()], a.Start, uint32(a.Start)+uint32(a.Count)-1))
+	}
+	if rule, triggered := slave.triggerBusy(pdu, now); triggered {
+		h.protocolPort.Info(fmt.Sprintf("slave %d busy for %s", pdu.UnitId, rule.Duration))
+		if rule.Acknowledge {
+			return NewExceptionPDU(pdu, ExAcknowledge)
+		}
+	}
+	return res
+}
+
+// processSlave answers pdu by the connected slave.
+func (h *Gateway) processSlave(slave *Slave, pdu PDU) *PDU {
+	if !slave.authorizeWrite(pdu) {
+		h.protocolPort.Info(fmt.Sprintf("write to slave %d rejected for role %q", pdu.UnitId, pdu.Role))
+		return NewExceptionPDU(pdu, ExIllegalFunction)
+	}
+
+	if slave.forwards(pdu) {
+		return slave.Process(pdu)
+	}
+
 	switch pdu.FunctionCode {
 	case FC2ReadDiscreteRegisters, FC6WriteSingleRegister, FC17ReadWriteMultipleRegisters:
 		return slave.Process(pdu)
@@ -137,6 +376,50 @@ func (h *Gateway) processPDU(pdu PDU) *PDU {
 		return res
 	}
 
+	if pdu.FunctionCode == FC15WriteMultipleCoils {
+		// FC15 payload format: [startAddr(2 bytes)][quantity(2 bytes)][byteCount(1 byte)][coils(N bytes)]
+		// Each byte contains up to 8 coils, the lowest address in the least significant bit
+		if len(pdu.Payload) < 5 {
+			slog.Debug("FC15 invalid payload length", "got", len(pdu.Payload))
+			return nil
+		}
+		quantity := encoding.BytesToUint16(pdu.Payload[2:4])
+		slog.Debug("processPDU", "regAddr", fmt.Sprintf("%X", addr), "quantitiy", quantity, "pdu", pdu)
+		byteCount := pdu.Payload[4]
+
+		// Validate byte count matches quantity and payload length
+		if int(byteCount) != (int(quantity)+7)/8 || len(pdu.Payload) < 5+int(byteCount) {
+			slog.Debug("FC15 invalid byte count", "quantity", quantity, "byteCount", byteCount, "got", len(pdu.Payload))
+			return nil
+		}
+
+		// Store the coil values like FC5 (0xFF00 for true, 0x0000 for false)
+		values := ""
+		for i := range quantity {
+			currentAddr := addr + i
+			var value uint16
+			if pdu.Payload[5+i/8]&(1<<(i%8)) != 0 {
+				value = 0xFF00
+			}
+			slave.registers[currentAddr] = value
+			if len(values) > 0 {
+				values += ", "
+			}
+			values += fmt.Sprintf("0x%X => 0x%X", currentAddr, value)
+		}
+		h.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("TX FC=%d UnitID=%d Address=0x%04X Quantity=%d ByteCount=%d Values: %s",
+			pdu.FunctionCode, pdu.UnitId, addr, quantity, byteCount, values)))
+
+		// FC15 response: echo back starting address and quantity
+		res := &PDU{
+			UnitId:       pdu.UnitId,
+			FunctionCode: pdu.FunctionCode,
+			Payload:      pdu.Payload[0:4],
+		}
+		h.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("RX FC=%d UnitID=%d Payload=% X", res.FunctionCode, res.UnitId, res.Payload)))
+		return res
+	}
+
 	if pdu.FunctionCode == FC16WriteMultipleRegisters {
 		// FC16 payload format: [startAddr(2 bytes)][quantity(2 bytes)][byteCount(1 byte)][values(N bytes)]
 		// addr and quantity already extracted at the beginning
@@ -190,6 +473,9 @@ func (h *Gateway) processPDU(pdu PDU) *PDU {
 }
 
 func (g *Gateway) ConnectSlave(unitID uint8, url string) error {
+	g.slaveLock.Lock()
+	defer g.slaveLock.Unlock()
+
 	if _, exists := g.slaves[url]; !exists {
 		return fmt.Errorf("URl %s not configured", url)
 	}
@@ -207,14 +493,65 @@ func (g *Gateway) ConnectSlave(unitID uint8, url string) error {
 
 // ConnectSlaveWithConfig connects a slave with configuration including rules
 func (h *Gateway) ConnectSlaveWithConfig(slaveConfig config.Slave, url string) {
+	h.slaveLock.Lock()
+	defer h.slaveLock.Unlock()
+	h.connectSlaveWithConfig(slaveConfig, url)
+}
+
+// connectSlaveWithConfig is ConnectSlaveWithConfig for callers holding the
+// slaveLock.
+func (h *Gateway) connectSlaveWithConfig(slaveConfig config.Slave, url string) {
 	if _, exists := h.slaves[url][slaveConfig.ID]; !exists {
 		ruleEngine := rules.NewEngine(slaveConfig.Rules)
-		h.slaves[url][slaveConfig.ID] = NewSlave(slaveConfig.ID, true, ruleEngine, h.protocolPort)
+		slave := NewSlave(slaveConfig.ID, true, ruleEngine, h.protocolPort)
+		slave.configure(slaveConfig)
+		h.slaves[url][slaveConfig.ID] = slave
 		slog.Debug("Slave connected with rules", "unitID", slaveConfig.ID, "url", url, "ruleCount", len(slaveConfig.Rules))
 	}
 }
 
+// ConnectProxySlave connects a slave that forwards requests to upstream,
+// except for requests addressing its local register ranges.
+func (h *Gateway) ConnectProxySlave(slaveConfig config.Slave, url string, upstream Upstream) {
+	h.slaveLock.Lock()
+	defer h.slaveLock.Unlock()
+	if _, exists := h.slaves[url][slaveConfig.ID]; !exists {
+		slave := NewSlave(slaveConfig.ID, true, rules.NewEngine(slaveConfig.Rules), h.protocolPort)
+		slave.upstream = upstream
+		slave.upstreamUnitID = slaveConfig.ID
+		if slaveConfig.Upstream.UnitID != 0 {
+			slave.upstreamUnitID = slaveConfig.Upstream.UnitID
+		}
+		slave.local = slaveConfig.Local
+		slave.configure(slaveConfig)
+		h.slaves[url][slaveConfig.ID] = slave
+		slog.Debug("Proxy slave connected", "unitID", slaveConfig.ID, "url", url, "upstream", upstream.Description())
+	}
+}
+
+// ConnectShadowSlave connects a slave like ConnectSlaveWithConfig, whose
+// requests are also sent to the reference slave. Responses of the reference
+// that differ from the local ones are reported.
+func (h *Gateway) ConnectShadowSlave(slaveConfig config.Slave, url string, reference Upstream) {
+	h.slaveLock.Lock()
+	defer h.slaveLock.Unlock()
+	if _, exists := h.slaves[url][slaveConfig.ID]; !exists {
+		slave := NewSlave(slaveConfig.ID, true, rules.NewEngine(slaveConfig.Rules), h.protocolPort)
+		slave.configure(slaveConfig)
+		unitID := slaveConfig.ID
+		if slaveConfig.Shadow.UnitID != 0 {
+			unitID = slaveConfig.Shadow.UnitID
+		}
+		slave.shadow = newShadow(reference, unitID, slaveConfig.Shadow.TimingThreshold, h.protocolPort)
+		h.slaves[url][slaveConfig.ID] = slave
+		slog.Debug("Shadow slave connected", "unitID", slaveConfig.ID, "url", url, "reference", reference.Description())
+	}
+}
+
 func (h *Gateway) DisconnectSlave(unitID uint8) {
+	h.slaveLock.Lock()
+	defer h.slaveLock.Unlock()
+
 	for _, v := range h.slaves {
 		if _, exists := v[unitID]; exists {
 			v[unitID].connected = false
@@ -224,37 +561,129 @@ func (h *Gateway) DisconnectSlave(unitID uint8) {
 
 // WriteRegister writes one or more uint16 values to consecutive registers
 // on the slave identified by unitID, starting at addr.
+// The access ranges of the slave's register map don't apply.
 func (g *Gateway) WriteRegister(
 	unitID uint8,
 	addr uint16,
 	values []uint16,
 ) error {
 	g.slaveLock.Lock()
-	defer g.slaveLock.Unlock()
-
 	slave, exists := g.findSlave(unitID)
-	if !exists || !slave.connected {
+	connected := exists && slave.connected
+	g.slaveLock.Unlock()
+	if !connected {
 		return fmt.Errorf("slave %d not found", unitID)
 	}
+	slave.lock.Lock()
+	defer slave.lock.Unlock()
 	for i, v := range values {
 		slave.registers[addr+uint16(i)] = v
 	}
 	return nil
 }
 
+// SetFaults switches fault injection on or off for the transport at url, or
+// for all transports with faults if url is empty.
+func (h *Gateway) SetFaults(url string, enabled bool) error {
+	switched := false
+	for _, p := range h.handler {
+		if url != "" && p.Description() != url {
+			continue
+		}
+		if f, ok := p.(FaultInjector); ok && f.Faults() != nil {
+			f.Faults().SetEnabled(enabled)
+			switched = true
+		}
+	}
+	if !switched {
+		if url == "" {
+			return errors.New("no faults configured")
+		}
+		return fmt.Errorf("no faults configured for %s", url)
+	}
+	return nil
+}
+
+// SetNetwork switches the network emulation on or off for the transport at
+// url, or for all transports with network conditions if url is empty.
+func (h *Gateway) SetNetwork(url string, enabled bool) error {
+	switched := false
+	for _, p := range h.handler {
+		if url != "" && p.Description() != url {
+			continue
+		}
+		if n, ok := p.(NetworkEmulator); ok && n.Network() != nil {
+			n.Network().SetEnabled(enabled)
+			switched = true
+		}
+	}
+	if !switched {
+		if url == "" {
+			return errors.New("no network conditions configured")
+		}
+		return fmt.Errorf("no network conditions configured for %s", url)
+	}
+	return nil
+}
+
+// SetBusy lets the slave identified by unitID answer the function codes fcs,
+// or all if fcs is empty, with exception 06 for the duration d. A duration of
+// 0 ends the busy window.
+func (h *Gateway) SetBusy(unitID uint8, d time.Duration, fcs []uint8) error {
+	h.slaveLock.Lock()
+	slave, exists := h.findSlave(unitID)
+	h.slaveLock.Unlock()
+	if !exists {
+		return fmt.Errorf("slave %d not found", unitID)
+	}
+	slave.lock.Lock()
+	defer slave.lock.Unlock()
+	slave.setBusy(time.Now(), d, fcs)
+	return nil
+}
+
 func (h *Gateway) Status() string {
 	var status string
 	for i, p := range h.handler {
 		status = fmt.Sprintf("%sPort %d: %s", status, i, p.Description())
-		if len(h.slaves[p.Description()]) == 0 {
-			status += "\n  <no slaves connected>"
+		if r, ok := p.(StatusReporter); ok {
+			status += r.Status()
+		}
+		status += h.unitIDStatus(p.Description())
+		if h.broadcasts[p.Description()] {
+			status += "\n  Broadcasts: enabled"
+		}
+		if template, promiscuous := h.templates[p.Description()]; promiscuous {
+			status += fmt.Sprintf("\n  Promiscuous: unknown slaves created from template %q", template.Name)
+		}
+		for unitID, bus := range h.routes[p.Description()] {
+			status += fmt.Sprintf("\n  - Unit %d: routed to %s", unitID, bus.Description())
+		}
+		// Snapshot the slaves, so that the status doesn't block other slaves
+		// while waiting for a slave processing a request
+		type unit struct {
+			slave     *Slave
+			connected bool
 		}
+		h.slaveLock.Lock()
+		units := make(map[uint8]unit)
 		for unitID, slave := range h.slaves[p.Description()] {
+			units[unitID] = unit{slave, slave.connected}
+		}
+		h.slaveLock.Unlock()
+
+		if len(units) == 0 && len(h.routes[p.Description()]) == 0 {
+			status += "\n  <no slaves connected>"
+		}
+		for unitID, u := range units {
+			slave := u.slave
 			connectStatus := "disconnected"
-			if slave.connected {
+			if u.connected {
 				connectStatus = "connected"
 			}
 			status = fmt.Sprintf("%s\n  - Unit %d: %s", status, unitID, connectStatus)
+			slave.lock.Lock()
+			status += slave.status()
 			status += slave.ruleEngine.Status()
 			if len(slave.registers) > 0 {
 				for addr, value := range slave.registers {
@@ -262,6 +691,7 @@ func (h *Gateway) Status() string {
 					status += fmt.Sprintf("\n    - 0x%X => 0x%X", addr, value)
 				}
 			}
+			slave.lock.Unlock()
 		}
 	}
 	return status
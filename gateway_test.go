@@ -0,0 +1,201 @@
+package modbuslabs_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/encoding"
+	"github.com/rwirdemann/modbuslabs/message"
+	"github.com/rwirdemann/modbuslabs/pty"
+	"github.com/rwirdemann/modbuslabs/rtu"
+	"github.com/rwirdemann/modbuslabs/tcp"
+	"github.com/rwirdemann/modbuslabs/upstream"
+)
+
+// protocol records the protocol output of a slavesim.
+type protocol struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (p *protocol) InfoX(m message.Message) { p.Info(m.String()) }
+func (p *protocol) Println(msg string)      { p.Info(msg) }
+func (p *protocol) Separator()              {}
+func (p *protocol) ForceSeparator()         {}
+func (p *protocol) Mute()                   {}
+func (p *protocol) Unmute()                 {}
+func (p *protocol) Toggle()                 {}
+
+func (p *protocol) Info(msg string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lines = append(p.lines, msg)
+}
+
+// find returns the first protocol line containing substr.
+func (p *protocol) find(substr string) (string, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, line := range p.lines {
+		if strings.Contains(line, substr) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// await waits up to a second for a protocol line containing substr.
+func (p *protocol) await(substr string) (string, bool) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if line, ok := p.find(substr); ok {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// slavesim is a gateway serving its slaves on a single transport.
+type slavesim struct {
+	*modbuslabs.Gateway
+	address  string
+	protocol *protocol
+}
+
+// startSlavesim starts a slavesim on a TCP transport at a free local port.
+// connect connects the slaves to the gateway before it is started.
+func startSlavesim(t *testing.T, connect func(s *slavesim)) *slavesim {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &slavesim{address: l.Addr().String(), protocol: &protocol{}}
+	_ = l.Close()
+
+	h, err := tcp.NewHandler("tcp://"+s.address, tcp.Options{}, s.protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start(t, h, connect)
+	return s
+}
+
+// startRTUSlavesim starts a slavesim on an RTU transport at address, which
+// is connected to peerAddress by a native pseudo-terminal pair.
+func startRTUSlavesim(t *testing.T, address, peerAddress string, connect func(s *slavesim)) *slavesim {
+	t.Helper()
+	if !pty.Supported() {
+		t.Skip("native pseudo-terminals are not supported")
+	}
+	s := &slavesim{address: address, protocol: &protocol{}}
+	s.start(t, rtu.NewHandler(address, pty.NewPair(address, peerAddress), rtu.Options{}, s.protocol), connect)
+	return s
+}
+
+func (s *slavesim) start(t *testing.T, h modbuslabs.TransportHandler, connect func(s *slavesim)) {
+	t.Helper()
+	s.Gateway = modbuslabs.NewGateway([]modbuslabs.TransportHandler{h}, s.protocol)
+	connect(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Start(ctx); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = s.Stop()
+	})
+}
+
+// master is a Modbus master sending requests to a slavesim.
+type master struct {
+	t      *testing.T
+	client *upstream.Client
+}
+
+func newMaster(t *testing.T, address string, timeout time.Duration) *master {
+	t.Helper()
+	client, err := upstream.NewClient("tcp://"+address, upstream.Options{Timeout: timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return &master{t: t, client: client}
+}
+
+// writeRegister writes value to register addr of slave unitID with FC6.
+func (m *master) writeRegister(unitID uint8, addr, value uint16) {
+	m.t.Helper()
+	payload := append(encoding.Uint16ToBytes(addr), encoding.Uint16ToBytes(value)...)
+	res, err := m.client.Send(modbuslabs.PDU{UnitId: unitID, FunctionCode: modbuslabs.FC6WriteSingleRegister, Payload: payload})
+	if err != nil {
+		m.t.Fatalf("write 0x%04X to register 0x%04X of slave %d: %v", value, addr, unitID, err)
+	}
+	if res.FunctionCode != modbuslabs.FC6WriteSingleRegister {
+		m.t.Fatalf("write 0x%04X to register 0x%04X of slave %d: response %s", value, addr, unitID, res)
+	}
+}
+
+// readRequest returns the FC4 request reading quantity registers from addr
+// of slave unitID.
+func readRequest(unitID uint8, addr, quantity uint16) modbuslabs.PDU {
+	payload := append(encoding.Uint16ToBytes(addr), encoding.Uint16ToBytes(quantity)...)
+	return modbuslabs.PDU{UnitId: unitID, FunctionCode: modbuslabs.FC4ReadInputRegisters, Payload: payload}
+}
+
+// readRegisters reads quantity registers from addr of slave unitID with FC4.
+func (m *master) readRegisters(unitID uint8, addr, quantity uint16) []uint16 {
+	m.t.Helper()
+	res, err := m.client.Send(readRequest(unitID, addr, quantity))
+	if err != nil {
+		m.t.Fatalf("read %d registers from 0x%04X of slave %d: %v", quantity, addr, unitID, err)
+	}
+	if res.FunctionCode != modbuslabs.FC4ReadInputRegisters || len(res.Payload) != 1+2*int(quantity) {
+		m.t.Fatalf("read %d registers from 0x%04X of slave %d: response %s", quantity, addr, unitID, res)
+	}
+	var values []uint16
+	for i := 1; i < len(res.Payload); i += 2 {
+		values = append(values, encoding.BytesToUint16(res.Payload[i:i+2]))
+	}
+	return values
+}
+
+func TestRoutesToSerialBus(t *testing.T) {
+	dir := t.TempDir()
+	busAddress, masterAddress := filepath.Join(dir, "ttyS0"), filepath.Join(dir, "ttyS1")
+	startRTUSlavesim(t, busAddress, masterAddress, func(s *slavesim) {
+		s.ConnectSlaveWithConfig(config.Slave{ID: 10, Address: s.address}, s.address)
+	})
+	bus, err := upstream.NewClient("rtu://"+masterAddress, upstream.Options{Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := startSlavesim(t, func(s *slavesim) {
+		s.AddRoute(s.address, []uint8{10, 11}, bus)
+	})
+	m := newMaster(t, gateway.address, time.Second)
+
+	m.writeRegister(10, 0x0002, 42)
+	if got := m.readRegisters(10, 0x0002, 1); got[0] != 42 {
+		t.Errorf("register 0x0002 of unit 10 = %d, want 42", got[0])
+	}
+
+	// Unit 11 is routed to the bus, but no slave answers
+	res, err := m.client.Send(readRequest(11, 0x0002, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := modbuslabs.NewExceptionPDU(readRequest(11, 0x0002, 1), modbuslabs.ExGatewayTargetFailedToRespond)
+	if res.FunctionCode != want.FunctionCode || !slices.Equal(res.Payload, want.Payload) {
+		t.Errorf("response %s, want %s", res, want)
+	}
+}
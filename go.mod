@@ -4,11 +4,10 @@ go 1.25.0
 
 require github.com/goburrow/modbus v0.1.0
 
-require github.com/goburrow/serial v0.1.0
-
 require (
-	github.com/BurntSushi/toml v1.5.0 // indirect
-	github.com/chzyer/readline v1.5.1 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/term v0.36.0 // indirect
+	github.com/BurntSushi/toml v1.5.0
+	github.com/chzyer/readline v1.5.1
+	github.com/goburrow/serial v0.1.0
+	golang.org/x/sys v0.37.0
+	golang.org/x/term v0.36.0
 )
@@ -0,0 +1,189 @@
+// Package learn remembers the register values a real device reported while
+// slavesim forwarded or sniffed its traffic and writes them as slave
+// configuration, so that the device can be cloned into a simulation.
+package learn
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/encoding"
+)
+
+// coilOn is the register value of a coil or discrete input that is on, like
+// written by FC5.
+const coilOn uint16 = 0xFF00
+
+// slave identifies a learned slave by its unit ID and transport address.
+type slave struct {
+	unitID  uint8
+	address string
+}
+
+// Learner collects the register values of observed responses. It is safe for
+// concurrent use.
+type Learner struct {
+	lock      sync.Mutex
+	slaves    map[slave]map[uint16]uint16
+	responses int
+}
+
+// New creates an empty learner.
+func New() *Learner {
+	return &Learner{slaves: make(map[slave]map[uint16]uint16)}
+}
+
+// Observe learns the register values carried by a request and its response.
+// The values are assigned to the slave with unitID served at address in the
+// generated configuration. Exception responses are ignored.
+func (l *Learner) Observe(address string, unitID uint8, req, res modbuslabs.PDU) {
+	values := registerValues(req, res)
+	if len(values) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := slave{unitID: unitID, address: address}
+	if l.slaves[key] == nil {
+		l.slaves[key] = make(map[uint16]uint16)
+	}
+	maps.Copy(l.slaves[key], values)
+	l.responses++
+}
+
+// Upstream returns an upstream that forwards to up and learns every
+// exchange for the slave with unitID served at address.
+func (l *Learner) Upstream(up modbuslabs.Upstream, address string, unitID uint8) modbuslabs.Upstream {
+	return &upstream{Upstream: up, learner: l, address: address, unitID: unitID}
+}
+
+type upstream struct {
+	modbuslabs.Upstream
+	learner *Learner
+	address string
+	unitID  uint8
+}
+
+func (u *upstream) Send(pdu modbuslabs.PDU) (*modbuslabs.PDU, error) {
+	res, err := u.Upstream.Send(pdu)
+	if err == nil {
+		u.learner.Observe(u.address, u.unitID, pdu, *res)
+	}
+	return res, err
+}
+
+// Write writes a slave section with the learned values for every observed
+// slave. Consecutive addresses are combined into one register range.
+func (l *Learner) Write(w io.Writer) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Learned from %d responses on %s\n", l.responses, time.Now().Format(time.DateTime))
+	keys := slices.SortedFunc(maps.Keys(l.slaves), func(a, b slave) int {
+		if c := strings.Compare(a.address, b.address); c != 0 {
+			return c
+		}
+		return int(a.unitID) - int(b.unitID)
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n[[slave]]\nid = %d\naddress = %q\n", key.unitID, key.address)
+		registers := l.slaves[key]
+		addresses := slices.Sorted(maps.Keys(registers))
+		for i := 0; i < len(addresses); {
+			// Collect the values of the range starting at addresses[i].
+			start := addresses[i]
+			var values []string
+			for ; i < len(addresses) && addresses[i] == start+uint16(len(values)); i++ {
+				values = append(values, fmt.Sprintf("0x%04X", registers[addresses[i]]))
+			}
+			fmt.Fprintf(&b, "\n  [[slave.register]]\n  address = 0x%04X\n  values = [%s]\n", start, strings.Join(values, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Save writes the learned configuration to the file at path.
+func (l *Learner) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create learned configuration: %w", err)
+	}
+	if err := l.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// registerValues returns the register values read or written by req as
+// confirmed by res.
+func registerValues(req, res modbuslabs.PDU) map[uint16]uint16 {
+	if res.FunctionCode != req.FunctionCode || len(req.Payload) < 4 {
+		return nil
+	}
+	addr := encoding.BytesToUint16(req.Payload[0:2])
+	values := make(map[uint16]uint16)
+
+	switch req.FunctionCode {
+	case modbuslabs.FC1ReadCoils, modbuslabs.FC2ReadDiscreteRegisters:
+		quantity := encoding.BytesToUint16(req.Payload[2:4])
+		if len(res.Payload) < 1 {
+			return nil
+		}
+		setBits(values, addr, quantity, res.Payload[1:])
+	case modbuslabs.FC3ReadHoldingRegisters, modbuslabs.FC4ReadInputRegisters:
+		if len(res.Payload) < 1 {
+			return nil
+		}
+		setRegisters(values, addr, res.Payload[1:])
+	case modbuslabs.FC5WriteSingleCoil, modbuslabs.FC6WriteSingleRegister:
+		values[addr] = encoding.BytesToUint16(req.Payload[2:4])
+	case modbuslabs.FC15WriteMultipleCoils:
+		if len(req.Payload) < 5 {
+			return nil
+		}
+		setBits(values, addr, encoding.BytesToUint16(req.Payload[2:4]), req.Payload[5:])
+	case modbuslabs.FC16WriteMultipleRegisters:
+		if len(req.Payload) < 5 {
+			return nil
+		}
+		setRegisters(values, addr, req.Payload[5:])
+	case modbuslabs.FC17ReadWriteMultipleRegisters:
+		// Written values are overwritten by the read ones if the ranges
+		// overlap, the read values reflect the device's state afterwards.
+		if len(req.Payload) < 9 || len(res.Payload) < 1 {
+			return nil
+		}
+		setRegisters(values, encoding.BytesToUint16(req.Payload[4:6]), req.Payload[9:])
+		setRegisters(values, addr, res.Payload[1:])
+	}
+	return values
+}
+
+func setRegisters(values map[uint16]uint16, addr uint16, data []byte) {
+	for i := 0; i+1 < len(data); i += 2 {
+		values[addr+uint16(i/2)] = encoding.BytesToUint16(data[i : i+2])
+	}
+}
+
+func setBits(values map[uint16]uint16, addr, quantity uint16, data []byte) {
+	for i := range quantity {
+		if int(i/8) >= len(data) {
+			return
+		}
+		values[addr+i] = 0
+		if data[i/8]&(1<<(i%8)) != 0 {
+			values[addr+i] = coilOn
+		}
+	}
+}
@@ -7,25 +7,92 @@ import (
 )
 
 const (
+	FC1ReadCoils                   uint8 = 0x01
 	FC2ReadDiscreteRegisters       uint8 = 0x02
+	FC3ReadHoldingRegisters        uint8 = 0x03
 	FC4ReadInputRegisters          uint8 = 0x04
 	FC5WriteSingleCoil             uint8 = 0x05
 	FC6WriteSingleRegister         uint8 = 0x06
+	FC15WriteMultipleCoils         uint8 = 0x0F
 	FC16WriteMultipleRegisters     uint8 = 0x10
 	FC17ReadWriteMultipleRegisters uint8 = 0x17
 )
 
+// Modbus exception codes returned in the single payload byte of an exception
+// response.
+const (
+	ExIllegalFunction              uint8 = 0x01
+	ExIllegalDataAddress           uint8 = 0x02
+	ExIllegalDataValue             uint8 = 0x03
+	ExAcknowledge                  uint8 = 0x05
+	ExSlaveDeviceBusy              uint8 = 0x06
+	ExGatewayPathUnavailable       uint8 = 0x0A
+	ExGatewayTargetFailedToRespond uint8 = 0x0B
+)
+
 // PDU is a struct to represent a Modbus Protocol Data unit.
 type PDU struct {
 	UnitId       uint8
 	FunctionCode uint8
 	Payload      []byte
+
+	// Role is the Modbus/TCP Security role of the client that sent the
+	// request. It is empty for requests received on unsecured transports.
+	Role string
 }
 
 func (p PDU) String() string {
 	return fmt.Sprintf("UnitId:%d FC:%d Payload:% X", p.UnitId, p.FunctionCode, p.Payload)
 }
 
+// NewExceptionPDU returns the exception response for req carrying the given
+// exception code.
+func NewExceptionPDU(req PDU, code uint8) *PDU {
+	return &PDU{
+		UnitId:       req.UnitId,
+		FunctionCode: req.FunctionCode | 0x80,
+		Payload:      []byte{code},
+	}
+}
+
+// WriteRange returns the first register address and the number of registers
+// written by req. ok is false if req is not a write request or its payload is
+// too short.
+func WriteRange(req PDU) (addr uint16, quantity uint16, ok bool) {
+	switch req.FunctionCode {
+	case FC5WriteSingleCoil, FC6WriteSingleRegister:
+		if len(req.Payload) < 4 {
+			return 0, 0, false
+		}
+		return encoding.BytesToUint16(req.Payload[0:2]), 1, true
+	case FC15WriteMultipleCoils, FC16WriteMultipleRegisters:
+		if len(req.Payload) < 4 {
+			return 0, 0, false
+		}
+		return encoding.BytesToUint16(req.Payload[0:2]), encoding.BytesToUint16(req.Payload[2:4]), true
+	case FC17ReadWriteMultipleRegisters:
+		if len(req.Payload) < 8 {
+			return 0, 0, false
+		}
+		return encoding.BytesToUint16(req.Payload[4:6]), encoding.BytesToUint16(req.Payload[6:8]), true
+	}
+	return 0, 0, false
+}
+
+// ReadRange returns the first register address and the number of registers
+// read by req. ok is false if req is not a read request or its payload is too
+// short.
+func ReadRange(req PDU) (addr uint16, quantity uint16, ok bool) {
+	switch req.FunctionCode {
+	case FC2ReadDiscreteRegisters, FC4ReadInputRegisters, FC17ReadWriteMultipleRegisters:
+		if len(req.Payload) < 4 {
+			return 0, 0, false
+		}
+		return encoding.BytesToUint16(req.Payload[0:2]), encoding.BytesToUint16(req.Payload[2:4]), true
+	}
+	return 0, 0, false
+}
+
 // AssembleMBAPFrame turns a PDU into an MBAP frame (MBAP header + PDU) and returns it as bytes.
 func AssembleMBAPFrame(txnId uint16, p *PDU) []byte {
 	// transaction identifier
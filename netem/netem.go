@@ -0,0 +1,122 @@
+// Package netem emulates bad network links, e.g. slow or unreliable mobile
+// connections, for the responses written by a transport.
+package netem
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Options defines the conditions of the emulated link. The zero value is a
+// perfect link.
+type Options struct {
+	Latency   time.Duration // delay of every response
+	Jitter    time.Duration // random deviation of the latency, up to ±Jitter
+	Bandwidth int           // bytes per second, 0 = unlimited
+	BytePause time.Duration // write responses byte by byte with this pause in between
+	HalfOpen  float64       // probability of a response turning the connection half-open
+	Reset     float64       // probability of a connection being reset while writing a response
+}
+
+// Emulator applies the link conditions to the responses of a transport. It
+// is safe for concurrent use.
+type Emulator struct {
+	options Options
+	enabled atomic.Bool
+}
+
+// New creates an enabled emulator.
+func New(options Options) *Emulator {
+	e := &Emulator{options: options}
+	e.enabled.Store(true)
+	return e
+}
+
+// SetEnabled switches the emulation on or off. A disabled emulator behaves
+// like a perfect link.
+func (e *Emulator) SetEnabled(enabled bool) {
+	e.enabled.Store(enabled)
+}
+
+// Enabled reports whether e is not nil and switched on.
+func (e *Emulator) Enabled() bool {
+	return e != nil && e.enabled.Load()
+}
+
+// HalfOpen draws whether the connection turns half-open instead of sending
+// the next response, i.e. it receives requests but never sends a byte again.
+func (e *Emulator) HalfOpen() bool {
+	return e.Enabled() && rand.Float64() < e.options.HalfOpen
+}
+
+// Reset draws whether the connection is reset while sending a response of n
+// bytes and returns the number of bytes sent before the reset.
+func (e *Emulator) Reset(n int) (int, bool) {
+	if !e.Enabled() || rand.Float64() >= e.options.Reset {
+		return 0, false
+	}
+	return rand.IntN(n + 1), true
+}
+
+// Write writes b to w after the latency. With a bandwidth cap, writing
+// takes as long as b needs on the link. With a byte pause, every byte is
+// written separately.
+func (e *Emulator) Write(w io.Writer, b []byte) (int, error) {
+	if !e.Enabled() {
+		return w.Write(b)
+	}
+
+	latency := e.options.Latency
+	if e.options.Jitter > 0 {
+		latency += rand.N(2*e.options.Jitter+1) - e.options.Jitter
+	}
+	time.Sleep(max(latency, 0))
+
+	var perByte time.Duration
+	if e.options.Bandwidth > 0 {
+		perByte = time.Second / time.Duration(e.options.Bandwidth)
+	}
+	if e.options.BytePause <= 0 {
+		time.Sleep(time.Duration(len(b)) * perByte)
+		return w.Write(b)
+	}
+	for i := range b {
+		if i > 0 {
+			time.Sleep(e.options.BytePause)
+		}
+		time.Sleep(perByte)
+		if _, err := w.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(b), nil
+}
+
+// Status describes the link conditions and whether they are emulated.
+func (e *Emulator) Status() string {
+	state := "on"
+	if !e.enabled.Load() {
+		state = "off"
+	}
+	var conditions []string
+	if o := e.options; o.Latency > 0 || o.Jitter > 0 {
+		conditions = append(conditions, fmt.Sprintf("latency %s±%s", o.Latency, o.Jitter))
+	}
+	if e.options.Bandwidth > 0 {
+		conditions = append(conditions, fmt.Sprintf("bandwidth %d B/s", e.options.Bandwidth))
+	}
+	if e.options.BytePause > 0 {
+		conditions = append(conditions, fmt.Sprintf("byte pause %s", e.options.BytePause))
+	}
+	if e.options.HalfOpen > 0 {
+		conditions = append(conditions, fmt.Sprintf("half-open %g%%", e.options.HalfOpen*100))
+	}
+	if e.options.Reset > 0 {
+		conditions = append(conditions, fmt.Sprintf("reset %g%%", e.options.Reset*100))
+	}
+	return fmt.Sprintf("\n  Network (%s): %s", state, strings.Join(conditions, ", "))
+}
@@ -0,0 +1,151 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ModbusPort is the server port of synthesized TCP segments. Wireshark
+// decodes traffic on this port as Modbus/TCP without further configuration.
+const ModbusPort = 502
+
+// TCP flags of synthesized segments.
+const (
+	flagFIN uint8 = 0x01
+	flagSYN uint8 = 0x02
+	flagPSH uint8 = 0x08
+	flagACK uint8 = 0x10
+)
+
+// Stream synthesizes the IPv4 and TCP headers for the MBAP frames of one
+// client connection, including the handshake and the connection teardown.
+// The capture must use LinkTypeRaw.
+type Stream struct {
+	w                    *Writer
+	lock                 sync.Mutex
+	client, server       netip.AddrPort
+	clientSeq, serverSeq uint32
+	closed               bool
+}
+
+// OpenStream writes the TCP handshake between client and server and returns
+// the stream for their frames. Both addresses must be IPv4 addresses.
+func (w *Writer) OpenStream(client, server netip.AddrPort) *Stream {
+	s := &Stream{w: w, client: client, server: server, clientSeq: 1000, serverSeq: 5000}
+	now := time.Now()
+	s.write(now, true, flagSYN, nil)
+	s.clientSeq++
+	s.write(now, false, flagSYN|flagACK, nil)
+	s.serverSeq++
+	s.write(now, true, flagACK, nil)
+	return s
+}
+
+// Request writes frame sent by the client.
+func (s *Stream) Request(frame []byte) {
+	s.data(true, frame)
+}
+
+// Response writes frame sent by the server.
+func (s *Stream) Response(frame []byte) {
+	s.data(false, frame)
+}
+
+// Close writes the connection teardown, initiated by the server.
+func (s *Stream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	now := time.Now()
+	s.write(now, false, flagFIN|flagACK, nil)
+	s.serverSeq++
+	s.write(now, true, flagFIN|flagACK, nil)
+	s.clientSeq++
+	s.write(now, false, flagACK, nil)
+}
+
+func (s *Stream) data(fromClient bool, frame []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.write(time.Now(), fromClient, flagPSH|flagACK, frame)
+	if fromClient {
+		s.clientSeq += uint32(len(frame))
+	} else {
+		s.serverSeq += uint32(len(frame))
+	}
+}
+
+// write writes one segment. Caller must hold the lock.
+func (s *Stream) write(t time.Time, fromClient bool, flags uint8, payload []byte) {
+	src, dst, seq, ack := s.client, s.server, s.clientSeq, s.serverSeq
+	if !fromClient {
+		src, dst, seq, ack = s.server, s.client, s.serverSeq, s.clientSeq
+	}
+	if flags&flagACK == 0 {
+		ack = 0
+	}
+	if err := s.w.WritePacket(t, segment(src, dst, seq, ack, flags, payload)); err != nil {
+		slog.Debug("writing capture failed", "path", s.w.path, "err", err)
+	}
+}
+
+// segment returns an IPv4 packet carrying a TCP segment.
+func segment(src, dst netip.AddrPort, seq, ack uint32, flags uint8, payload []byte) []byte {
+	p := make([]byte, 40+len(payload))
+
+	// IPv4 header
+	ip := p[:20]
+	ip[0] = 0x45 // version 4, header length 5 words
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(p)))
+	ip[6] = 0x40 // don't fragment
+	ip[8] = 64   // TTL
+	ip[9] = 6    // TCP
+	srcIP, dstIP := src.Addr().As4(), dst.Addr().As4()
+	copy(ip[12:16], srcIP[:])
+	copy(ip[16:20], dstIP[:])
+	binary.BigEndian.PutUint16(ip[10:12], checksum(ip, 0))
+
+	// TCP header
+	tcp := p[20:]
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	binary.BigEndian.PutUint32(tcp[8:12], ack)
+	tcp[12] = 5 << 4 // header length 5 words
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:16], 0xFFFF) // window
+	copy(tcp[20:], payload)
+
+	// The TCP checksum covers a pseudo header of addresses, protocol and
+	// segment length.
+	var pseudo uint32
+	for i := 0; i < 4; i += 2 {
+		pseudo += uint32(binary.BigEndian.Uint16(srcIP[i:])) + uint32(binary.BigEndian.Uint16(dstIP[i:]))
+	}
+	pseudo += 6 + uint32(len(tcp))
+	binary.BigEndian.PutUint16(tcp[16:18], checksum(tcp, pseudo))
+	return p
+}
+
+// checksum returns the internet checksum of b, starting with sum.
+func checksum(b []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,197 @@
+// Package pty creates virtual serial port pairs from two pseudo-terminals
+// without depending on external tools like socat. Bytes written to one side
+// of the pair are relayed to the other side.
+package pty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ErrUnsupported is returned by Open on platforms without pseudo-terminal
+// support.
+var ErrUnsupported = errors.New("native pseudo-terminals not supported on this platform")
+
+// Supported reports whether native pseudo-terminals are available on this
+// platform.
+func Supported() bool {
+	return supported
+}
+
+// terminal is one pseudo-terminal of a pair. The handle to the slave side is
+// kept open, so that reading from the master side does not fail while no
+// client has the port opened.
+type terminal struct {
+	master *os.File
+	slave  *os.File
+	name   string // path of the slave side, e.g. /dev/pts/3
+}
+
+func (t *terminal) close() {
+	_ = t.master.Close()
+	_ = t.slave.Close()
+}
+
+// Tap receives every chunk of bytes relayed by a pair. toPeer is true for
+// bytes written at address and relayed to peerAddress. b must not be retained
+// after the call returns.
+type Tap func(toPeer bool, b []byte)
+
+// Pair is a virtual serial port pair. The slave sides of both terminals are
+// linked at address and peerAddress.
+type Pair struct {
+	address     string
+	peerAddress string
+	a, b        *terminal
+	wg          sync.WaitGroup
+	tap         Tap
+
+	lock     sync.Mutex
+	closing  bool
+	relayErr error // set if a relay stopped before Close
+}
+
+// NewPair creates a pair linked at address (used by slavesim) and
+// peerAddress (used by the master). The pair is created by Open.
+func NewPair(address, peerAddress string) *Pair {
+	return &Pair{address: address, peerAddress: peerAddress}
+}
+
+// SetTap makes the pair pass all relayed bytes to tap. It must be called
+// before Open.
+func (p *Pair) SetTap(tap Tap) {
+	p.tap = tap
+}
+
+// Open creates both pseudo-terminals, links them at the configured addresses
+// and starts relaying data between them.
+func (p *Pair) Open() error {
+	a, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	b, err := openTerminal()
+	if err != nil {
+		a.close()
+		return err
+	}
+
+	if err := link(a.name, p.address); err != nil {
+		a.close()
+		b.close()
+		return err
+	}
+	if err := link(b.name, p.peerAddress); err != nil {
+		_ = os.Remove(p.address)
+		a.close()
+		b.close()
+		return err
+	}
+
+	p.a, p.b = a, b
+	p.closing = false
+	p.relayErr = nil
+	p.wg.Add(2)
+	go p.relay(a, b)
+	go p.relay(b, a)
+	slog.Debug("pty pair created", "address", p.address, "tty", a.name, "peer_address", p.peerAddress, "peer_tty", b.name)
+	return nil
+}
+
+// Close stops the relay, closes both terminals and removes the links.
+func (p *Pair) Close() error {
+	if p.a == nil {
+		return nil
+	}
+	p.lock.Lock()
+	p.closing = true
+	p.lock.Unlock()
+	p.a.close()
+	p.b.close()
+	p.wg.Wait()
+	p.a, p.b = nil, nil
+
+	err := errors.Join(removeLink(p.address), removeLink(p.peerAddress))
+	slog.Debug("pty pair closed", "address", p.address, "peer_address", p.peerAddress)
+	return err
+}
+
+// Status describes the health of the terminals backing the pair.
+func (p *Pair) Status() string {
+	if p.a == nil {
+		return fmt.Sprintf("pty %s <-> %s: closed", p.address, p.peerAddress)
+	}
+	status := fmt.Sprintf("pty %s (%s) <-> %s (%s): ", p.address, p.a.name, p.peerAddress, p.b.name)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.relayErr != nil {
+		return status + fmt.Sprintf("relay stopped (%s)", p.relayErr)
+	}
+	return status + "running"
+}
+
+func (p *Pair) relay(from, to *terminal) {
+	defer p.wg.Done()
+	var src io.Reader = from.master
+	if p.tap != nil {
+		src = &tapReader{r: from.master, tap: p.tap, toPeer: from == p.a}
+	}
+	_, err := io.Copy(to.master, src)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closing {
+		return
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	p.relayErr = fmt.Errorf("%s -> %s: %w", from.name, to.name, err)
+	slog.Debug("pty relay stopped", "from", from.name, "to", to.name, "err", err)
+}
+
+// tapReader passes everything read from r to tap.
+type tapReader struct {
+	r      io.Reader
+	tap    Tap
+	toPeer bool
+}
+
+func (t *tapReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	if n > 0 {
+		t.tap(t.toPeer, b[:n])
+	}
+	return n, err
+}
+
+// link creates a symlink to tty at path. A symlink left at path by a previous
+// run is replaced, any other file is not touched.
+func link(tty, path string) error {
+	if err := removeLink(path); err != nil {
+		return err
+	}
+	if err := os.Symlink(tty, path); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", path, tty, err)
+	}
+	return nil
+}
+
+func removeLink(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symlink", path)
+	}
+	return os.Remove(path)
+}
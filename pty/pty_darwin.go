@@ -0,0 +1,47 @@
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+const supported = true
+
+// openTerminal opens a new pseudo-terminal via /dev/ptmx, grants and unlocks
+// its slave side.
+func openTerminal() (*terminal, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+
+	name := make([]byte, 128)
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+			return err
+		}
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+			return err
+		}
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&name[0])))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	return openSlave(master, string(name[:bytes.IndexByte(name, 0)]))
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package pty
+
+const supported = false
+
+func openTerminal() (*terminal, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,61 @@
+//go:build linux || darwin
+
+package pty
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openSlave opens the slave side of master at name and puts it into raw
+// mode, so that the line discipline passes binary frames unchanged.
+func openSlave(master *os.File, name string) (*terminal, error) {
+	slave, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+
+	err = control(slave, func(fd int) error {
+		t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+		if err != nil {
+			return err
+		}
+		makeRaw(t)
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, t)
+	})
+	if err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to set %s to raw mode: %w", name, err)
+	}
+
+	return &terminal{master: master, slave: slave, name: name}, nil
+}
+
+// makeRaw configures t like cfmakeraw(3).
+func makeRaw(t *unix.Termios) {
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+}
+
+// control runs fn with the file descriptor of f without switching f to
+// blocking mode, so that Close still interrupts pending reads.
+func control(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,104 @@
+package modbuslabs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/encoding"
+)
+
+// tableAccess is the access of a request to one data table.
+type tableAccess struct {
+	table    string
+	ranges   []config.RegisterRange // declared ranges of the table, all addresses valid if empty
+	addr     uint16
+	quantity uint16
+	write    bool
+}
+
+// tableAccesses returns the data table accesses of pdu. Coils are accessed
+// by FC1, FC5 and FC15, discrete inputs by FC2, holding registers by FC3,
+// FC6, FC16 and FC17 and input registers by FC4.
+func (s *Slave) tableAccesses(pdu PDU) []tableAccess {
+	m := s.registerMap
+	if len(pdu.Payload) < 4 {
+		return nil
+	}
+	addr, quantity := encoding.BytesToUint16(pdu.Payload[0:2]), encoding.BytesToUint16(pdu.Payload[2:4])
+	switch pdu.FunctionCode {
+	case FC1ReadCoils:
+		return []tableAccess{{"coil", m.Coils, addr, quantity, false}}
+	case FC15WriteMultipleCoils:
+		return []tableAccess{{"coil", m.Coils, addr, quantity, true}}
+	case FC5WriteSingleCoil:
+		return []tableAccess{{"coil", m.Coils, addr, 1, true}}
+	case FC2ReadDiscreteRegisters:
+		return []tableAccess{{"discrete input", m.DiscreteInputs, addr, quantity, false}}
+	case FC3ReadHoldingRegisters:
+		return []tableAccess{{"holding register", m.HoldingRegisters, addr, quantity, false}}
+	case FC16WriteMultipleRegisters:
+		return []tableAccess{{"holding register", m.HoldingRegisters, addr, quantity, true}}
+	case FC6WriteSingleRegister:
+		return []tableAccess{{"holding register", m.HoldingRegisters, addr, 1, true}}
+	case FC4ReadInputRegisters:
+		return []tableAccess{{"input register", m.InputRegisters, addr, quantity, false}}
+	case FC17ReadWriteMultipleRegisters:
+		writeAddr, writeQuantity, ok := WriteRange(pdu)
+		if !ok {
+			return nil
+		}
+		return []tableAccess{
+			{"holding register", m.HoldingRegisters, addr, quantity, false},
+			{"holding register", m.HoldingRegisters, writeAddr, writeQuantity, true},
+		}
+	}
+	return nil
+}
+
+// illegalAddress describes the first access of pdu to an address outside
+// the declared register map, including accesses spanning a gap between two
+// ranges. It reports false if all addresses are valid.
+func (s *Slave) illegalAddress(pdu PDU) (string, bool) {
+	for _, a := range s.tableAccesses(pdu) {
+		if len(a.ranges) == 0 {
+			continue
+		}
+		for i := range uint32(a.quantity) {
+			addr := uint32(a.addr) + i
+			if addr > 0xFFFF || !slices.ContainsFunc(a.ranges, func(r config.RegisterRange) bool { return r.Contains(uint16(addr)) }) {
+				return fmt.Sprintf("%s 0x%04X is not mapped", a.table, addr), true
+			}
+		}
+	}
+	return "", false
+}
+
+// mapStatus describes the declared register map, its access ranges and
+// value constraints.
+func (s *Slave) mapStatus() string {
+	m := s.registerMap
+	var status string
+	for _, t := range []struct {
+		name   string
+		ranges []config.RegisterRange
+	}{
+		{"Coils", m.Coils}, {"Discrete inputs", m.DiscreteInputs},
+		{"Holding registers", m.HoldingRegisters}, {"Input registers", m.InputRegisters},
+	} {
+		if len(t.ranges) == 0 {
+			continue
+		}
+		var ranges []string
+		for _, r := range t.ranges {
+			ranges = append(ranges, fmt.Sprintf("0x%04X-0x%04X", r.Start, r.End()))
+		}
+		status += fmt.Sprintf("\n    - %s: %s", t.name, strings.Join(ranges, ", "))
+	}
+	status += s.accessStatus() + s.constraintStatus()
+	if status == "" {
+		return ""
+	}
+	return "\n    Map:" + status
+}
@@ -5,39 +5,106 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/goburrow/serial"
 	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/fault"
+	"github.com/rwirdemann/modbuslabs/pcap"
 )
 
+// VirtualPair is a virtual serial port pair. The handler serves one side of
+// the pair, the master connects to the other one.
+type VirtualPair interface {
+	Open() error
+	Close() error
+	Status() string
+}
+
+// reopenInterval is the delay between attempts to reopen a failed serial
+// port, e.g. while socat is being restarted.
+const reopenInterval = 500 * time.Millisecond
+
+// Options configures the serial line of a Handler. Zero values select 9600
+// baud, 8 data bits, no parity and 1 stop bit without line timing.
+type Options struct {
+	BaudRate        int
+	DataBits        int
+	Parity          string // "N", "E" or "O"
+	StopBits        int
+	LineTiming      bool            // pace echo and responses to match the baud rate and character framing
+	TurnaroundDelay time.Duration   // delay between the end of a request and the start of its response
+	Echo            bool            // emulate RS-485 half-duplex echo of the request bytes
+	PcapFile        string          // record all frames to this PCAP file, "" = no capture
+	Faults          *fault.Injector // faults injected into responses, nil = none
+}
+
 // Start starts the RTU handler.
 type Handler struct {
 	serialPort   serial.Port
+	serialConfig *serial.Config
+	portLock     sync.Mutex
+	stopped      bool
+	done         chan struct{} // closed when the request cycle ends, nil before Start
 	url          string
+	options      Options
+	pair         VirtualPair
+	capture      *pcap.Writer // nil without capture
 	protocolPort modbuslabs.ProtocolPort
 }
 
-// NewHandler creates a new RTU handler.
-func NewHandler(url string, protocolPort modbuslabs.ProtocolPort) *Handler {
-	return &Handler{url: url, protocolPort: protocolPort}
+// NewHandler creates a new RTU handler. If pair is not nil, the handler
+// creates the pair on Start and removes it on Stop.
+func NewHandler(url string, pair VirtualPair, options Options, protocolPort modbuslabs.ProtocolPort) *Handler {
+	if options.BaudRate == 0 {
+		options.BaudRate = 9600
+	}
+	if options.DataBits == 0 {
+		options.DataBits = 8
+	}
+	if options.Parity == "" {
+		options.Parity = "N"
+	}
+	if options.StopBits == 0 {
+		options.StopBits = 1
+	}
+	return &Handler{url: url, pair: pair, options: options, protocolPort: protocolPort}
 }
 
 func (h *Handler) Start(ctx context.Context, processPDU modbuslabs.ProcessPDUCallback) (err error) {
-	config := &serial.Config{
+	if h.options.PcapFile != "" {
+		if h.capture, err = pcap.Create(h.options.PcapFile, pcap.LinkTypeUser0); err != nil {
+			return err
+		}
+	}
+
+	if h.pair != nil {
+		if err := h.pair.Open(); err != nil {
+			h.closeCapture()
+			return fmt.Errorf("failed to create virtual serial port: %w", err)
+		}
+	}
+
+	h.serialConfig = &serial.Config{
 		Address:  h.url,
-		BaudRate: 9600,
-		DataBits: 8,
-		Parity:   "N",
-		StopBits: 1,
+		BaudRate: h.options.BaudRate,
+		DataBits: h.options.DataBits,
+		Parity:   h.options.Parity,
+		StopBits: h.options.StopBits,
 		Timeout:  5 * time.Second,
 	}
 
-	h.serialPort, err = serial.Open(config)
+	h.serialPort, err = serial.Open(h.serialConfig)
 	if err != nil {
+		if h.pair != nil {
+			_ = h.pair.Close()
+		}
+		h.closeCapture()
 		return fmt.Errorf("failed to open serial port: %w", err)
 	}
 
+	h.done = make(chan struct{})
 	go h.startRequestCycle(ctx, processPDU)
 	slog.Debug("RTU listener started", "url", h.url)
 	return nil
@@ -47,21 +114,103 @@ func (h *Handler) Description() string {
 	return h.url
 }
 
+// Faults returns the fault injector of the handler, nil if no faults are
+// configured.
+func (h *Handler) Faults() *fault.Injector {
+	return h.options.Faults
+}
+
+// Status describes the virtual serial port pair, if any, and the injected
+// faults.
+func (h *Handler) Status() string {
+	var status string
+	if h.pair != nil {
+		status = "\n  " + h.pair.Status()
+	}
+	if h.options.Faults != nil {
+		status += h.options.Faults.Status()
+	}
+	return status
+}
+
+// port returns the currently open serial port or nil once the handler has
+// been stopped.
+func (h *Handler) port() serial.Port {
+	h.portLock.Lock()
+	defer h.portLock.Unlock()
+	if h.stopped {
+		return nil
+	}
+	return h.serialPort
+}
+
+// reopen closes the failed serial port and opens it again. It retries until
+// the port is available again, e.g. after the virtual port pair has been
+// restarted, or the handler is stopped.
+func (h *Handler) reopen(ctx context.Context) {
+	h.portLock.Lock()
+	_ = h.serialPort.Close()
+	h.portLock.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reopenInterval):
+		}
+
+		h.portLock.Lock()
+		if h.stopped {
+			h.portLock.Unlock()
+			return
+		}
+		port, err := serial.Open(h.serialConfig)
+		if err == nil {
+			h.serialPort = port
+			h.portLock.Unlock()
+			h.protocolPort.Info(fmt.Sprintf("serial port %s reopened", h.url))
+			return
+		}
+		h.portLock.Unlock()
+		slog.Debug("reopening serial port failed", "url", h.url, "err", err)
+	}
+}
+
 func (h *Handler) startRequestCycle(ctx context.Context, processPDU modbuslabs.ProcessPDUCallback) {
+	defer close(h.done)
 	buffer := make([]byte, 256)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			n, err := h.serialPort.Read(buffer)
+			serialPort := h.port()
+			if serialPort == nil {
+				return
+			}
+			n, err := serialPort.Read(buffer)
 			if err != nil {
 				if err.Error() != "EOF" && err.Error() != "serial: timeout" {
-					slog.Error("Error reading from serial port", "err", err)
+					if h.port() == nil {
+						return
+					}
+					h.protocolPort.Info(fmt.Sprintf("serial port %s failed: %s, reopening", h.url, err))
+					h.reopen(ctx)
+					continue
 				}
 				time.Sleep(100 * time.Millisecond)
 				continue
 			}
+			if n == 0 {
+				// The port was readable but returned no data: the other end
+				// of the line hung up, e.g. because socat terminated.
+				if h.port() == nil {
+					return
+				}
+				h.protocolPort.Info(fmt.Sprintf("serial port %s hung up, reopening", h.url))
+				h.reopen(ctx)
+				continue
+			}
 
 			// Sample FC16 Request to write float32:
 			//
@@ -106,36 +255,27 @@ func (h *Handler) startRequestCycle(ctx context.Context, processPDU modbuslabs.P
 				}
 				pdu.UnitId = data[0]
 				pdu.FunctionCode = data[1]
-				pdu.Payload = data[2:n]
+				pdu.Payload = data[2 : n-2]
 
 				h.protocolPort.Separator()
 				h.protocolPort.Info(fmt.Sprintf("Incomming request on /virtual/com0 => %d", pdu.UnitId))
 				h.protocolPort.Info(fmt.Sprintf("TX % X", data))
+				h.record(data)
 
 				// Verify CRC
 				receivedCRC := binary.LittleEndian.Uint16(data[len(data)-2:])
-				calculatedCRC := calculateCRC(data[:len(data)-2])
+				calculatedCRC := CRC(data[:len(data)-2])
 				if receivedCRC != calculatedCRC {
 					h.protocolPort.Info("crc's are not equal")
 					continue
 				}
 
 				res := processPDU(*pdu)
+				h.awaitTurnaround(serialPort, data)
 
 				// Echo back the request as response
 				if res != nil {
-					// Build complete RTU frame: UnitId + FunctionCode + Payload + CRC
-					response := make([]byte, 0, 2+len(res.Payload))
-					response = append(response, res.UnitId)
-					response = append(response, res.FunctionCode)
-					response = append(response, res.Payload...)
-
-					// Calculate and append CRC
-					crc := calculateCRC(response)
-					response = append(response, byte(crc&0xFF), byte(crc>>8))
-
-					h.serialPort.Write(response)
-					h.protocolPort.Info(fmt.Sprintf("RX % X", response))
+					h.writeResponse(serialPort, res)
 				}
 			}
 			h.protocolPort.Separator()
@@ -143,16 +283,94 @@ func (h *Handler) startRequestCycle(ctx context.Context, processPDU modbuslabs.P
 	}
 }
 
+// writeResponse writes res as RTU frame to port, unless the fault injector
+// decides otherwise.
+func (h *Handler) writeResponse(port serial.Port, res *modbuslabs.PDU) {
+	plan := h.options.Faults.Draw(res.UnitId)
+	if !plan.Empty() {
+		h.protocolPort.Info(fmt.Sprintf("FAULT %s for unit %d", plan, res.UnitId))
+	}
+	if plan.Drop {
+		return
+	}
+	time.Sleep(plan.Delay)
+
+	// Build complete RTU frame: UnitId + FunctionCode + Payload + CRC
+	response := make([]byte, 0, 2+len(res.Payload))
+	response = append(response, res.UnitId)
+	response = append(response, res.FunctionCode)
+	response = append(response, res.Payload...)
+	if plan.FlipBits {
+		// Flipped before the CRC is calculated, so that the master receives
+		// a valid frame with wrong data.
+		fault.FlipBit(response[2:])
+	}
+
+	// Calculate and append CRC
+	crc := CRC(response)
+	if plan.CorruptCRC {
+		crc = ^crc
+	}
+	response = append(response, byte(crc&0xFF), byte(crc>>8))
+	if plan.Truncate {
+		response = fault.TruncateFrame(response)
+	}
+
+	writes := 1
+	if plan.Duplicate {
+		writes = 2
+	}
+	for range writes {
+		h.writeFrame(port, response)
+		h.record(response)
+		h.protocolPort.Info(fmt.Sprintf("RX % X", response))
+	}
+}
+
 // Stop stops the handler.
 func (h *Handler) Stop() error {
 	slog.Debug("Closing serial port")
+	h.portLock.Lock()
+	h.stopped = true
+	h.portLock.Unlock()
+
+	// The serial port must not be closed while the request cycle reads from
+	// it. Closing the virtual pair hangs up the line, which ends a pending
+	// read, so the port is closed once the request cycle has ended.
+	var err error
+	if h.pair != nil {
+		err = h.pair.Close()
+		if h.done != nil {
+			<-h.done
+		}
+	}
+	h.portLock.Lock()
 	if h.serialPort != nil {
 		h.serialPort.Close()
 	}
-	return nil
+	h.portLock.Unlock()
+	h.closeCapture()
+	return err
+}
+
+func (h *Handler) closeCapture() {
+	if h.capture != nil {
+		_ = h.capture.Close()
+	}
+}
+
+// record writes frame to the capture file, if any.
+func (h *Handler) record(frame []byte) {
+	if h.capture == nil {
+		return
+	}
+	if err := h.capture.WritePacket(time.Now(), frame); err != nil {
+		slog.Debug("writing capture failed", "path", h.capture.Path(), "err", err)
+	}
 }
 
-func calculateCRC(data []byte) uint16 {
+// CRC returns the Modbus RTU CRC-16 of data. It is sent low byte first.
+func CRC(data []byte) uint16 {
 	crc := uint16(0xFFFF)
 	for _, b := range data {
 		crc ^= uint16(b)
@@ -0,0 +1,67 @@
+package rtu
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/goburrow/serial"
+)
+
+// charTime returns the time needed to transmit one character, including
+// start, parity and stop bits, at the configured baud rate.
+func (o Options) charTime() time.Duration {
+	bits := 1 + o.DataBits + o.StopBits
+	if o.Parity != "N" {
+		bits++
+	}
+	return time.Duration(bits) * time.Second / time.Duration(o.BaudRate)
+}
+
+// frameGap returns the silent interval of 3.5 characters that separates two
+// frames. Above 19200 baud the specification fixes it at 1.75ms.
+func (o Options) frameGap() time.Duration {
+	if o.BaudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return o.charTime() * 7 / 2
+}
+
+// awaitTurnaround waits until a response to request may be sent. With echo,
+// the request bytes are sent back first, like an RS-485 transceiver does in
+// half-duplex mode, even if no slave answers. With line timing, the time the request needs on the wire
+// and the silent interval after it are waited for as well.
+func (h *Handler) awaitTurnaround(port serial.Port, request []byte) {
+	if h.options.Echo {
+		h.writeFrame(port, request)
+		h.protocolPort.Info(fmt.Sprintf("EC % X", request))
+	} else if h.options.LineTiming {
+		time.Sleep(time.Duration(len(request)) * h.options.charTime())
+	}
+	if h.options.LineTiming {
+		time.Sleep(h.options.frameGap())
+	}
+	time.Sleep(h.options.TurnaroundDelay)
+}
+
+// writeFrame writes frame to port. With line timing, every byte is written
+// when it would have been transmitted at the configured baud rate.
+func (h *Handler) writeFrame(port serial.Port, frame []byte) {
+	if !h.options.LineTiming {
+		if _, err := port.Write(frame); err != nil {
+			slog.Debug("write to serial port failed", "err", err)
+		}
+		return
+	}
+
+	start := time.Now()
+	charTime := h.options.charTime()
+	for i := range frame {
+		time.Sleep(time.Until(start.Add(time.Duration(i) * charTime)))
+		if _, err := port.Write(frame[i : i+1]); err != nil {
+			slog.Debug("write to serial port failed", "err", err)
+			return
+		}
+	}
+	time.Sleep(time.Until(start.Add(time.Duration(len(frame)) * charTime)))
+}
@@ -3,6 +3,7 @@ package rules
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/rwirdemann/modbuslabs/config"
 )
@@ -40,7 +41,7 @@ func (e *Engine) ApplyReadRules(register uint16, currentValue uint16) (uint16, b
 		return 0, false
 	}
 	for _, rule := range rules {
-		if !e.shouldTrigger(rule.Trigger, TriggerOnRead) {
+		if rule.Action == "busy" || !e.shouldTrigger(rule.Trigger, TriggerOnRead) {
 			continue
 		}
 		slog.Debug("Rule executed", "register", fmt.Sprintf("0x%04X", register), "trigger", rule.Trigger, "action", rule.Action, "oldValue", fmt.Sprintf("0x%04X", currentValue), "newValue", fmt.Sprintf("0x%04X", *rule.Value))
@@ -56,8 +57,10 @@ func (e *Engine) ApplyWriteRules(register uint16, currentValue uint16, registers
 		return 0, 0, false
 	}
 
+	// Other actions on write, e.g. require_role and busy, have no target
+	// register
 	for _, rule := range rules {
-		if !e.shouldTrigger(rule.Trigger, TriggerOnWrite) {
+		if rule.Action != "write_register" || !e.shouldTrigger(rule.Trigger, TriggerOnWrite) {
 			continue
 		}
 
@@ -69,6 +72,32 @@ func (e *Engine) ApplyWriteRules(register uint16, currentValue uint16, registers
 	return 0, 0, false
 }
 
+// AuthorizeWrite reports whether a client acting in the given role may write
+// register. Registers without a require_role rule are writable by everyone.
+func (e *Engine) AuthorizeWrite(register uint16, role string) bool {
+	for _, rule := range e.rules[register] {
+		if rule.Action != "require_role" || !e.shouldTrigger(rule.Trigger, TriggerOnWrite) {
+			continue
+		}
+		if !slices.Contains(rule.Roles, role) {
+			slog.Debug("Write rejected", "register", fmt.Sprintf("0x%04X", register), "role", role, "roles", rule.Roles)
+			return false
+		}
+	}
+	return true
+}
+
+// Busy returns the busy rule triggered by reading or writing value to
+// register, if any. Busy rules with a value only trigger on this value.
+func (e *Engine) Busy(register uint16, value uint16, trigger TriggerType) (config.Rule, bool) {
+	for _, rule := range e.rules[register] {
+		if rule.Action == "busy" && e.shouldTrigger(rule.Trigger, trigger) && (rule.Value == nil || *rule.Value == value) {
+			return rule, true
+		}
+	}
+	return config.Rule{}, false
+}
+
 func (e *Engine) Status() string {
 	if len(e.rules) == 0 {
 		return ""
@@ -77,6 +106,9 @@ func (e *Engine) Status() string {
 	for register, rules := range e.rules {
 		for i, r := range rules {
 			s = fmt.Sprintf("%s\n    - R%d: 0x%04X => %s %s", s, i+1, register, r.Trigger, r.Action)
+			if len(r.Roles) > 0 {
+				s += fmt.Sprintf(" %v", r.Roles)
+			}
 		}
 	}
 	return s
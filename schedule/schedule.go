@@ -0,0 +1,126 @@
+// Package schedule connects and disconnects slaves automatically, so that
+// masters can be tested against flapping connectivity without typing c and d
+// in the console.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+)
+
+// Modes
+const (
+	Periodic = "periodic" // connected for Online, disconnected for Offline, repeated
+	Random   = "random"   // outages with exponentially distributed gaps and durations
+	Events   = "events"   // changes at explicit times
+)
+
+// Schedule defines when a slave is connected and disconnected.
+type Schedule struct {
+	Mode    string
+	Online  time.Duration // periodic: time connected, random: mean time between outages
+	Offline time.Duration // periodic: time disconnected, random: mean outage duration
+	Events  []Event       // events only
+}
+
+// Event connects or disconnects a slave at At, or After the start of the
+// schedule if At is zero.
+type Event struct {
+	At        time.Time
+	After     time.Duration
+	Connected bool
+}
+
+// Run drives the connectivity of the slave with unitID on the transport at
+// url according to s until ctx is done. Every change is logged.
+func Run(ctx context.Context, s Schedule, unitID uint8, url string, controlPort modbuslabs.ControlPort, protocolPort modbuslabs.ProtocolPort) {
+	r := runner{ctx: ctx, unitID: unitID, url: url, controlPort: controlPort, protocolPort: protocolPort}
+	switch s.Mode {
+	case Periodic:
+		for r.wait(s.Online) {
+			if !r.set(false, s.Offline) || !r.wait(s.Offline) || !r.set(true, s.Online) {
+				return
+			}
+		}
+	case Random:
+		online := exponential(s.Online)
+		for r.wait(online) {
+			offline := exponential(s.Offline)
+			if !r.set(false, offline) || !r.wait(offline) {
+				return
+			}
+			online = exponential(s.Online)
+			if !r.set(true, online) {
+				return
+			}
+		}
+	case Events:
+		started := time.Now()
+		events := slices.Clone(s.Events)
+		for i, e := range events {
+			if e.At.IsZero() {
+				events[i].At = started.Add(e.After)
+			}
+		}
+		slices.SortStableFunc(events, func(a, b Event) int { return a.At.Compare(b.At) })
+		for _, e := range events {
+			if !r.wait(time.Until(e.At)) || !r.set(e.Connected, 0) {
+				return
+			}
+		}
+	}
+}
+
+type runner struct {
+	ctx          context.Context
+	unitID       uint8
+	url          string
+	controlPort  modbuslabs.ControlPort
+	protocolPort modbuslabs.ProtocolPort
+}
+
+// wait waits for d and reports whether the schedule goes on.
+func (r runner) wait(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// set connects or disconnects the slave and logs the change together with
+// the time until the next one, if known. It reports whether the schedule
+// goes on.
+func (r runner) set(connected bool, next time.Duration) bool {
+	state := "disconnected"
+	if connected {
+		if err := r.controlPort.ConnectSlave(r.unitID, r.url); err != nil {
+			r.protocolPort.Info(fmt.Sprintf("SCHEDULE slave %d on %s: %s", r.unitID, r.url, err))
+			return false
+		}
+		state = "connected"
+	} else {
+		r.controlPort.DisconnectSlave(r.unitID)
+	}
+
+	m := fmt.Sprintf("SCHEDULE slave %d on %s %s", r.unitID, r.url, state)
+	if next > 0 {
+		m += fmt.Sprintf(" for %s", next.Round(time.Millisecond))
+	}
+	r.protocolPort.Info(m)
+	return true
+}
+
+// exponential returns a random duration with the given mean, but at least
+// one millisecond.
+func exponential(mean time.Duration) time.Duration {
+	return max(time.Duration(rand.ExpFloat64()*float64(mean)), time.Millisecond)
+}
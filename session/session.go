@@ -0,0 +1,125 @@
+// Package session stores the requests a master sent to slavesim together with
+// the responses, so that the session can be replayed later.
+package session
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame is a request or response PDU.
+type Frame struct {
+	UnitID       uint8   `json:"unit_id"`
+	FunctionCode uint8   `json:"function_code"`
+	Payload      HexData `json:"payload"`
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("UnitID=%d FC=%d Payload=% X", f.UnitID, f.FunctionCode, []byte(f.Payload))
+}
+
+// Equal reports whether f and other carry the same PDU.
+func (f Frame) Equal(other Frame) bool {
+	return f.UnitID == other.UnitID && f.FunctionCode == other.FunctionCode && slices.Equal(f.Payload, other.Payload)
+}
+
+// Exchange is a request and the response it was answered with. Response is
+// nil if the request wasn't answered.
+type Exchange struct {
+	Offset    time.Duration `json:"offset"`    // arrival of the request since the first request of the session
+	Transport string        `json:"transport"` // address of the transport the request was received on
+	Request   Frame         `json:"request"`
+	Response  *Frame        `json:"response"`
+}
+
+// HexData is binary data encoded as hex string, e.g. "00 02 00 01".
+type HexData []byte
+
+func (d HexData) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("% X", []byte(d))), nil
+}
+
+func (d *HexData) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.ReplaceAll(string(text), " ", ""))
+	if err != nil {
+		return err
+	}
+	*d = b
+	return nil
+}
+
+// Writer writes exchanges to a session file, one JSON object per line. It is
+// safe for concurrent use.
+type Writer struct {
+	lock    sync.Mutex
+	file    *os.File
+	started time.Time // arrival of the first request
+}
+
+// Create creates the session file at path.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session file: %w", err)
+	}
+	return &Writer{file: f}, nil
+}
+
+// Write appends the exchange of a request that arrived at received.
+func (w *Writer) Write(received time.Time, e Exchange) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.started.IsZero() {
+		w.started = received
+	}
+	e.Offset = received.Sub(w.started)
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the session file.
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.file.Close()
+}
+
+// Read reads the exchanges of the session file at path, ordered by the
+// arrival of their requests.
+func Read(path string) ([]Exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer f.Close()
+
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		exchanges = append(exchanges, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(exchanges, func(a, b Exchange) int { return cmp.Compare(a.Offset, b.Offset) })
+	return exchanges, nil
+}
@@ -0,0 +1,156 @@
+package modbuslabs
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// shadowQueueSize is the number of answered requests waiting for the
+// reference slave. Requests are skipped while the queue is full, so that a
+// slow reference never delays the local responses.
+const shadowQueueSize = 64
+
+// shadow sends every request a slave answered locally to a reference slave,
+// e.g. the real device the slave simulates, and reports responses that
+// differ in payload, exception or timing.
+type shadow struct {
+	reference    Upstream
+	unitID       uint8         // unit ID of the reference slave
+	threshold    time.Duration // report timing differences above, 0 disables timing
+	protocolPort ProtocolPort
+
+	requests chan shadowRequest
+	stop     chan struct{}
+	done     chan struct{}
+
+	lock     sync.Mutex
+	compared int
+	differ   int
+}
+
+// shadowRequest is a request with the local response and the time it took
+// to answer it.
+type shadowRequest struct {
+	pdu     PDU
+	res     *PDU
+	elapsed time.Duration
+}
+
+func newShadow(reference Upstream, unitID uint8, threshold time.Duration, protocolPort ProtocolPort) *shadow {
+	s := &shadow{
+		reference:    reference,
+		unitID:       unitID,
+		threshold:    threshold,
+		protocolPort: protocolPort,
+		requests:     make(chan shadowRequest, shadowQueueSize),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// compare queues pdu and its local response res for the comparison with the
+// reference slave. The comparison runs in the background in request order.
+func (s *shadow) compare(pdu PDU, res *PDU, elapsed time.Duration) {
+	// The payloads may point into the read buffer of the transport, which is
+	// reused for the next request before the comparison runs.
+	pdu.Payload = slices.Clone(pdu.Payload)
+	if res != nil {
+		cloned := *res
+		cloned.Payload = slices.Clone(res.Payload)
+		res = &cloned
+	}
+	select {
+	case s.requests <- shadowRequest{pdu: pdu, res: res, elapsed: elapsed}:
+	default:
+		s.protocolPort.Info(fmt.Sprintf("SHADOW %s queue full, skipping FC=%d UnitID=%d", s.reference.Description(), pdu.FunctionCode, pdu.UnitId))
+	}
+}
+
+func (s *shadow) run() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case r := <-s.requests:
+			s.check(r)
+		}
+	}
+}
+
+// check sends the request of r to the reference slave and reports every
+// difference between both responses.
+func (s *shadow) check(r shadowRequest) {
+	req := r.pdu
+	req.UnitId = s.unitID
+	started := time.Now()
+	ref, err := s.reference.Send(req)
+	elapsed := time.Since(started)
+	if err != nil {
+		slog.Debug("reference slave failed", "reference", s.reference.Description(), "err", err)
+	}
+
+	var differences []string
+	switch {
+	case r.res == nil && ref == nil:
+	case r.res == nil:
+		differences = append(differences, fmt.Sprintf("no local response, reference % X", ref.Payload))
+	case ref == nil:
+		differences = append(differences, fmt.Sprintf("no reference response (%v)", err))
+	case isException(*r.res) || isException(*ref):
+		if r.res.FunctionCode != ref.FunctionCode || !bytes.Equal(r.res.Payload, ref.Payload) {
+			differences = append(differences, fmt.Sprintf("exception: local %s, reference %s", exception(*r.res), exception(*ref)))
+		}
+	case !bytes.Equal(r.res.Payload, ref.Payload):
+		differences = append(differences, fmt.Sprintf("payload: local % X, reference % X", r.res.Payload, ref.Payload))
+	}
+	if s.threshold > 0 && ref != nil && (elapsed-r.elapsed > s.threshold || r.elapsed-elapsed > s.threshold) {
+		differences = append(differences, fmt.Sprintf("timing: local %s, reference %s",
+			r.elapsed.Round(time.Microsecond), elapsed.Round(time.Microsecond)))
+	}
+
+	s.lock.Lock()
+	s.compared++
+	if len(differences) > 0 {
+		s.differ++
+	}
+	s.lock.Unlock()
+
+	for _, d := range differences {
+		s.protocolPort.Info(fmt.Sprintf("SHADOW FC=%d UnitID=%d Payload=% X differs in %s", r.pdu.FunctionCode, r.pdu.UnitId, r.pdu.Payload, d))
+	}
+}
+
+// close stops the comparison and closes the reference slave. A comparison in
+// progress is completed, queued requests are dropped.
+func (s *shadow) close() error {
+	close(s.stop)
+	<-s.done
+	return s.reference.Close()
+}
+
+// status describes the reference slave and the comparison results.
+func (s *shadow) status() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return fmt.Sprintf("\n    Shadow: %s unit %d, %d of %d responses differ", s.reference.Description(), s.unitID, s.differ, s.compared)
+}
+
+func isException(pdu PDU) bool {
+	return pdu.FunctionCode&0x80 != 0
+}
+
+// exception describes the exception code of pdu or "none" if pdu is a
+// regular response.
+func exception(pdu PDU) string {
+	if !isException(pdu) || len(pdu.Payload) == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("0x%02X", pdu.Payload[0])
+}
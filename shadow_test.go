@@ -0,0 +1,53 @@
+package modbuslabs_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/upstream"
+)
+
+func TestShadowReportsMismatches(t *testing.T) {
+	reference := startSlavesim(t, func(s *slavesim) {
+		s.ConnectSlaveWithConfig(config.Slave{
+			ID:        7,
+			Address:   s.address,
+			Registers: []config.Register{{Address: 0x0002, Values: []uint16{0x0005, 0x0009}}},
+		}, s.address)
+	})
+	local := startSlavesim(t, func(s *slavesim) {
+		client, err := upstream.NewClient("tcp://"+reference.address, upstream.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ConnectShadowSlave(config.Slave{
+			ID:        1,
+			Address:   s.address,
+			Registers: []config.Register{{Address: 0x0002, Values: []uint16{0x0004, 0x0009}}},
+			Shadow:    config.Shadow{Upstream: config.Upstream{Address: "tcp://" + reference.address, UnitID: 7}},
+		}, s.address, client)
+	})
+	m := newMaster(t, local.address, time.Second)
+
+	// Register 0x0003 matches the reference, 0x0002 doesn't
+	if got := m.readRegisters(1, 0x0003, 1); got[0] != 0x0009 {
+		t.Errorf("register 0x0003 = 0x%04X, want 0x0009", got[0])
+	}
+	if got := m.readRegisters(1, 0x0002, 2); got[0] != 0x0004 {
+		t.Errorf("register 0x0002 = 0x%04X, want the local value 0x0004", got[0])
+	}
+
+	want := "SHADOW FC=4 UnitID=1 Payload=00 02 00 02 differs in payload: local 04 00 04 00 09, reference 04 00 05 00 09"
+	if _, ok := local.protocol.await(want); !ok {
+		t.Errorf("protocol doesn't show %q", want)
+	}
+	// The responses are compared in request order
+	if line, ok := local.protocol.find("SHADOW FC=4 UnitID=1 Payload=00 03"); ok {
+		t.Errorf("matching response reported: %q", line)
+	}
+	if status := local.Status(); !strings.Contains(status, "1 of 2 responses differ") {
+		t.Errorf("status %q doesn't show 1 of 2 responses differ", status)
+	}
+}
@@ -1,27 +1,86 @@
 package modbuslabs
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/rwirdemann/modbuslabs/config"
 	"github.com/rwirdemann/modbuslabs/encoding"
 	"github.com/rwirdemann/modbuslabs/message"
 	"github.com/rwirdemann/modbuslabs/rules"
 )
 
+// Upstream is a remote slave, e.g. a real device, that a proxy slave
+// forwards requests to, or a serial bus the gateway routes requests to.
+// Send returns an error wrapping ErrUpstreamUnavailable if the connection
+// to the remote target can't be established.
+type Upstream interface {
+	Send(pdu PDU) (*PDU, error)
+	Close() error
+	Description() string
+}
+
+// ErrUpstreamUnavailable is returned by Upstream.Send if the remote target
+// can't be reached at all, e.g. because its serial port can't be opened.
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
 type Slave struct {
+	// lock guards the registers and the state below while a request is
+	// processed, connected is guarded by the gateway's slaveLock
+	lock sync.Mutex
+
 	unitID       uint8
 	registers    map[uint16]uint16
 	connected    bool
 	ruleEngine   *rules.Engine
 	protocolPort ProtocolPort
+
+	registerMap config.RegisterMap // declared valid addresses, see illegalAddress
+	access      []accessState      // state of registerMap.Access, see accessDenied
+
+	// Busy semantics, see busy
+	busyUntil       time.Time
+	busyFCs         []uint8 // function codes answered busy, all if empty
+	minPollInterval time.Duration
+	lastRequest     time.Time
+
+	// Proxy slaves only
+	upstream       Upstream
+	upstreamUnitID uint8
+	local          []config.RegisterRange
+
+	// Shadowed slaves only
+	shadow *shadow
 }
 
 func NewSlave(unitID uint8, connected bool, ruleEngine *rules.Engine, protocolPort ProtocolPort) *Slave {
 	return &Slave{unitID: unitID, registers: make(map[uint16]uint16), connected: connected, ruleEngine: ruleEngine, protocolPort: protocolPort}
 }
 
+// configure applies the configured initial register values, the minimum
+// poll interval and the register map with its access ranges.
+func (s *Slave) configure(slaveConfig config.Slave) {
+	s.minPollInterval = slaveConfig.MinPollInterval
+	s.registerMap = slaveConfig.Map
+	s.access = make([]accessState, len(slaveConfig.Map.Access))
+	for i := range s.access {
+		s.access[i].written = make(map[uint16]bool)
+	}
+	for _, r := range slaveConfig.Registers {
+		for i, v := range r.Values {
+			s.registers[r.Address+uint16(i)] = v
+		}
+	}
+}
+
 func (s *Slave) Process(pdu PDU) *PDU {
+	if s.forwards(pdu) {
+		return s.forward(pdu)
+	}
 	switch pdu.FunctionCode {
 	case FC2ReadDiscreteRegisters:
 		return s.processFC2(pdu)
@@ -33,6 +92,91 @@ func (s *Slave) Process(pdu PDU) *PDU {
 	return nil
 }
 
+// forwards reports whether pdu is forwarded upstream. Proxy slaves forward
+// every request except those that only address registers within their local
+// ranges.
+func (s *Slave) forwards(pdu PDU) bool {
+	if s.upstream == nil {
+		return false
+	}
+	readAddr, readQuantity, read := ReadRange(pdu)
+	writeAddr, writeQuantity, write := WriteRange(pdu)
+	if !read && !write {
+		return true
+	}
+	return (read && !s.isLocal(readAddr, readQuantity)) || (write && !s.isLocal(writeAddr, writeQuantity))
+}
+
+// isLocal reports whether all quantity registers starting at addr lie within
+// the local ranges of a proxy slave.
+func (s *Slave) isLocal(addr, quantity uint16) bool {
+	for i := range quantity {
+		if !slices.ContainsFunc(s.local, func(r config.RegisterRange) bool { return r.Contains(addr + i) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// forward sends pdu to the upstream slave and returns its response. No
+// response is returned if the upstream slave doesn't answer, just like the
+// slave itself would behave. The caller must hold the slave's lock, which is
+// released while waiting for the upstream slave.
+func (s *Slave) forward(pdu PDU) *PDU {
+	req := pdu
+	req.UnitId = s.upstreamUnitID
+	s.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("TX FC=%d UnitID=%d Payload=% X => %s UnitID=%d",
+		pdu.FunctionCode, pdu.UnitId, pdu.Payload, s.upstream.Description(), req.UnitId)))
+	s.protocolPort.Info(fmt.Sprintf("UP TX %s % X", s.upstream.Description(), append([]byte{req.UnitId, req.FunctionCode}, req.Payload...)))
+
+	// Like routed requests, forwarded requests don't touch the slave's state
+	// and must not block the slave while waiting for the upstream
+	s.lock.Unlock()
+	res, err := s.upstream.Send(req)
+	s.lock.Lock()
+	if err != nil {
+		s.protocolPort.Info(fmt.Sprintf("UP %s failed: %s", s.upstream.Description(), err))
+		return nil
+	}
+	s.protocolPort.Info(fmt.Sprintf("UP RX %s % X", s.upstream.Description(), append([]byte{res.UnitId, res.FunctionCode}, res.Payload...)))
+
+	res.UnitId = pdu.UnitId
+	s.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("RX FC=%d UnitID=%d Payload=% X", res.FunctionCode, res.UnitId, res.Payload)))
+	return res
+}
+
+// status describes the register map, the busy state, the upstream slave and local ranges of a
+// proxy slave or the reference of a shadowed slave.
+func (s *Slave) status() string {
+	status := s.mapStatus() + s.busyStatus()
+	if s.shadow != nil {
+		return status + s.shadow.status()
+	}
+	if s.upstream == nil {
+		return status
+	}
+	status += fmt.Sprintf("\n    Upstream: %s unit %d", s.upstream.Description(), s.upstreamUnitID)
+	for _, r := range s.local {
+		status += fmt.Sprintf("\n    - Local: 0x%04X-0x%04X", r.Start, r.End())
+	}
+	return status
+}
+
+// authorizeWrite reports whether the role the request was sent with may write
+// every register addressed by pdu. Read requests are always authorized.
+func (s *Slave) authorizeWrite(pdu PDU) bool {
+	addr, quantity, ok := WriteRange(pdu)
+	if !ok {
+		return true
+	}
+	for i := range quantity {
+		if !s.ruleEngine.AuthorizeWrite(addr+i, pdu.Role) {
+			return false
+		}
+	}
+	return true
+}
+
 // Response Payload:  [Byte Count] [Status Byte 1] [Status Byte 2] ... Each
 // status byte contains up to 8 coils.
 func (h *Slave) processFC2(pdu PDU) *PDU {
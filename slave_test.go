@@ -0,0 +1,91 @@
+package modbuslabs_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/upstream"
+)
+
+// startProxy starts an upstream slavesim serving slave 7 and a slavesim
+// serving proxy slave 1, which forwards to slave 7 except for registers
+// 0x0010 to 0x0013.
+func startProxy(t *testing.T) (proxy, up *slavesim) {
+	t.Helper()
+	up = startSlavesim(t, func(s *slavesim) {
+		s.ConnectSlaveWithConfig(config.Slave{ID: 7, Address: s.address}, s.address)
+	})
+	proxy = startSlavesim(t, func(s *slavesim) {
+		client, err := upstream.NewClient("tcp://"+up.address, upstream.Options{Timeout: 200 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ConnectProxySlave(config.Slave{
+			ID:       1,
+			Address:  s.address,
+			Type:     "proxy",
+			Upstream: config.Upstream{Address: "tcp://" + up.address, UnitID: 7},
+			Local:    []config.RegisterRange{{Start: 0x0010, Count: 4}},
+		}, s.address, client)
+	})
+	return proxy, up
+}
+
+func TestProxySlaveForwardsRequests(t *testing.T) {
+	proxy, up := startProxy(t)
+	m := newMaster(t, proxy.address, time.Second)
+
+	m.writeRegister(1, 0x0002, 42)
+	if got := newMaster(t, up.address, time.Second).readRegisters(7, 0x0002, 1); got[0] != 42 {
+		t.Errorf("upstream register 0x0002 = %d, want 42", got[0])
+	}
+	if got := m.readRegisters(1, 0x0002, 1); got[0] != 42 {
+		t.Errorf("proxy register 0x0002 = %d, want 42", got[0])
+	}
+	for _, line := range []string{"UP TX tcp://" + up.address + " 07 06 00 02 00 2A", "UP RX tcp://" + up.address + " 07 04 02 00 2A"} {
+		if _, ok := proxy.protocol.await(line); !ok {
+			t.Errorf("protocol doesn't show %q", line)
+		}
+	}
+}
+
+func TestProxySlaveServesLocalRegisters(t *testing.T) {
+	proxy, up := startProxy(t)
+	m := newMaster(t, proxy.address, time.Second)
+
+	m.writeRegister(1, 0x0011, 9)
+	if got := m.readRegisters(1, 0x0011, 1); got[0] != 9 {
+		t.Errorf("proxy register 0x0011 = %d, want 9", got[0])
+	}
+	if got := newMaster(t, up.address, time.Second).readRegisters(7, 0x0011, 1); got[0] != 0 {
+		t.Errorf("upstream register 0x0011 = %d, want 0", got[0])
+	}
+}
+
+func TestProxySlaveForwardsRequestsSpanningLocalRegisters(t *testing.T) {
+	proxy, up := startProxy(t)
+	m := newMaster(t, proxy.address, time.Second)
+	upstreamMaster := newMaster(t, up.address, time.Second)
+
+	m.writeRegister(1, 0x0010, 9)
+	upstreamMaster.writeRegister(7, 0x000F, 1)
+	upstreamMaster.writeRegister(7, 0x0010, 2)
+	if got := m.readRegisters(1, 0x000F, 2); !slices.Equal(got, []uint16{1, 2}) {
+		t.Errorf("proxy registers 0x000F-0x0010 = %v, want upstream values [1 2]", got)
+	}
+}
+
+func TestProxySlaveWithoutUpstream(t *testing.T) {
+	proxy, up := startProxy(t)
+	_ = up.Stop()
+
+	m := newMaster(t, proxy.address, time.Second)
+	if res, err := m.client.Send(readRequest(1, 0x0002, 1)); err == nil {
+		t.Fatalf("response %s, want none", res)
+	}
+	if _, ok := proxy.protocol.await("UP tcp://" + up.address + " failed"); !ok {
+		t.Error("protocol doesn't show why forwarding failed")
+	}
+}
@@ -0,0 +1,179 @@
+package sniffer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/encoding"
+	"github.com/rwirdemann/modbuslabs/rtu"
+)
+
+// frameLength returns the length of the RTU frame at the start of b,
+// including the CRC. It returns 0 if the length can't be determined yet, e.g.
+// because the byte count hasn't been received or the function is unknown.
+func frameLength(b []byte, request bool) int {
+	if len(b) < 2 {
+		return 0
+	}
+	fc := b[1]
+	if !request && fc&0x80 != 0 {
+		return 5
+	}
+	switch fc {
+	case modbuslabs.FC1ReadCoils, modbuslabs.FC2ReadDiscreteRegisters,
+		modbuslabs.FC3ReadHoldingRegisters, modbuslabs.FC4ReadInputRegisters:
+		if request {
+			return 8
+		}
+		return byteCountLength(b, 2, 3)
+	case modbuslabs.FC5WriteSingleCoil, modbuslabs.FC6WriteSingleRegister:
+		return 8
+	case modbuslabs.FC15WriteMultipleCoils, modbuslabs.FC16WriteMultipleRegisters:
+		if request {
+			return byteCountLength(b, 6, 7)
+		}
+		return 8
+	case modbuslabs.FC17ReadWriteMultipleRegisters:
+		if request {
+			return byteCountLength(b, 10, 11)
+		}
+		return byteCountLength(b, 2, 3)
+	}
+	return 0
+}
+
+// byteCountLength returns the length of a frame whose byte count is at index
+// i and followed by header more bytes plus the CRC.
+func byteCountLength(b []byte, i, header int) int {
+	if len(b) <= i {
+		return 0
+	}
+	return header + int(b[i]) + 2
+}
+
+// crcValid reports whether frame ends with a valid CRC.
+func crcValid(frame []byte) bool {
+	if len(frame) < 4 {
+		return false
+	}
+	return binary.LittleEndian.Uint16(frame[len(frame)-2:]) == rtu.CRC(frame[:len(frame)-2])
+}
+
+// describeRequest decodes the function code, addresses and values of a
+// request frame.
+func describeRequest(frame []byte) string {
+	if len(frame) < 4 {
+		return fmt.Sprintf("REQ incomplete frame % X", frame)
+	}
+	unitID, fc, data := frame[0], frame[1], frame[2:len(frame)-2]
+	s := fmt.Sprintf("REQ FC=%d UnitID=%d", fc, unitID)
+
+	switch fc {
+	case modbuslabs.FC1ReadCoils, modbuslabs.FC2ReadDiscreteRegisters,
+		modbuslabs.FC3ReadHoldingRegisters, modbuslabs.FC4ReadInputRegisters:
+		if len(data) >= 4 {
+			return s + fmt.Sprintf(" Address=0x%X Quantity=%d", word(data, 0), word(data, 2))
+		}
+	case modbuslabs.FC5WriteSingleCoil, modbuslabs.FC6WriteSingleRegister:
+		if len(data) >= 4 {
+			return s + fmt.Sprintf(" Address=0x%X Value=0x%X", word(data, 0), word(data, 2))
+		}
+	case modbuslabs.FC15WriteMultipleCoils:
+		if len(data) >= 5 {
+			addr, quantity := word(data, 0), word(data, 2)
+			return s + fmt.Sprintf(" Address=0x%X Quantity=%d Values=%v", addr, quantity, bits(data[5:], quantity))
+		}
+	case modbuslabs.FC16WriteMultipleRegisters:
+		if len(data) >= 5 {
+			addr, quantity := word(data, 0), word(data, 2)
+			return s + fmt.Sprintf(" Address=0x%X Quantity=%d Values=%s", addr, quantity, registers(addr, data[5:]))
+		}
+	case modbuslabs.FC17ReadWriteMultipleRegisters:
+		if len(data) >= 9 {
+			writeAddr := word(data, 4)
+			return s + fmt.Sprintf(" ReadAddr=0x%X ReadQty=%d WriteAddr=0x%X WriteQty=%d Values=%s",
+				word(data, 0), word(data, 2), writeAddr, word(data, 6), registers(writeAddr, data[9:]))
+		}
+	}
+	return s + fmt.Sprintf(" Payload=% X", data)
+}
+
+// describeResponse decodes a response frame. Read values are assigned to the
+// addresses of request, the frame the response answers, if known.
+func describeResponse(frame, request []byte) string {
+	if len(frame) < 4 {
+		return fmt.Sprintf("RES incomplete frame % X", frame)
+	}
+	unitID, fc, data := frame[0], frame[1], frame[2:len(frame)-2]
+	s := fmt.Sprintf("RES FC=%d UnitID=%d", fc, unitID)
+	if fc&0x80 != 0 {
+		if len(data) < 1 {
+			return fmt.Sprintf("RES incomplete frame % X", frame)
+		}
+		return s + fmt.Sprintf(" Exception=0x%02X", data[0])
+	}
+
+	// Read responses only carry the values, the addresses are taken from
+	// the request.
+	var addr, quantity uint16
+	matched := len(request) >= 8 && request[0] == unitID && request[1] == fc
+	if matched {
+		addr, quantity = word(request, 2), word(request, 4)
+	}
+
+	switch fc {
+	case modbuslabs.FC1ReadCoils, modbuslabs.FC2ReadDiscreteRegisters:
+		if len(data) >= 1 {
+			if !matched {
+				quantity = uint16(len(data)-1) * 8
+			}
+			return s + fmt.Sprintf(" Address=0x%X Values=%v", addr, bits(data[1:], quantity))
+		}
+	case modbuslabs.FC3ReadHoldingRegisters, modbuslabs.FC4ReadInputRegisters, modbuslabs.FC17ReadWriteMultipleRegisters:
+		if len(data) >= 1 {
+			return s + fmt.Sprintf(" Values=%s", registers(addr, data[1:]))
+		}
+	case modbuslabs.FC5WriteSingleCoil, modbuslabs.FC6WriteSingleRegister:
+		if len(data) >= 4 {
+			return s + fmt.Sprintf(" Address=0x%X Value=0x%X", word(data, 0), word(data, 2))
+		}
+	case modbuslabs.FC15WriteMultipleCoils, modbuslabs.FC16WriteMultipleRegisters:
+		if len(data) >= 4 {
+			return s + fmt.Sprintf(" Address=0x%X Quantity=%d", word(data, 0), word(data, 2))
+		}
+	}
+	return s + fmt.Sprintf(" Payload=% X", data)
+}
+
+// pdu returns the PDU carried by frame, which must have a valid CRC.
+func pdu(frame []byte) modbuslabs.PDU {
+	return modbuslabs.PDU{UnitId: frame[0], FunctionCode: frame[1], Payload: frame[2 : len(frame)-2]}
+}
+
+func word(b []byte, i int) uint16 {
+	return encoding.BytesToUint16(b[i : i+2])
+}
+
+// registers formats consecutive register values starting at addr.
+func registers(addr uint16, values []byte) string {
+	var s []string
+	for i := 0; i+1 < len(values); i += 2 {
+		s = append(s, fmt.Sprintf("0x%X => 0x%X", addr+uint16(i/2), word(values, i)))
+	}
+	return strings.Join(s, ", ")
+}
+
+// bits returns the first quantity coil or discrete input states packed into
+// b, least significant bit first.
+func bits(b []byte, quantity uint16) []bool {
+	values := make([]bool, 0, quantity)
+	for i := range quantity {
+		if int(i/8) >= len(b) {
+			break
+		}
+		values = append(values, b[i/8]&(1<<(i%8)) != 0)
+	}
+	return values
+}
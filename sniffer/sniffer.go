@@ -0,0 +1,166 @@
+// Package sniffer watches the traffic between a master and a slave on a
+// virtual serial line and decodes every RTU frame.
+package sniffer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/learn"
+	"github.com/rwirdemann/modbuslabs/message"
+	"github.com/rwirdemann/modbuslabs/pty"
+)
+
+// frameTimeout ends a frame whose length can't be derived from its content,
+// e.g. because of an unknown function code or a corrupted byte count. It is
+// well above the 3.5 character silent interval of common baud rates.
+const frameTimeout = 50 * time.Millisecond
+
+// Sniffer relays bytes between the master and the slave side of a virtual
+// serial port pair and logs every frame decoded, including its CRC validity
+// and the latency of responses.
+type Sniffer struct {
+	pair         *pty.Pair
+	slaveAddress string
+	protocolPort modbuslabs.ProtocolPort
+	learner      *learn.Learner
+
+	lock       sync.Mutex
+	requests   assembler
+	responses  assembler
+	request    []byte    // last request not answered yet
+	requestEnd time.Time // time the last byte of request was seen
+}
+
+// assembler collects the bytes of one direction until they form a frame.
+type assembler struct {
+	request bool
+	buf     []byte
+	started time.Time // arrival of the first byte in buf
+	last    time.Time // arrival of the last byte in buf
+	timer   *time.Timer
+	chunks  int // number of chunks received, tells flush whether it is stale
+}
+
+// New creates a sniffer whose master side is linked at masterAddress and
+// whose slave side is linked at slaveAddress. The ports are created by Open.
+func New(masterAddress, slaveAddress string, protocolPort modbuslabs.ProtocolPort) *Sniffer {
+	s := &Sniffer{
+		pair:         pty.NewPair(masterAddress, slaveAddress),
+		slaveAddress: slaveAddress,
+		protocolPort: protocolPort,
+		requests:     assembler{request: true},
+	}
+	s.pair.SetTap(s.tap)
+	return s
+}
+
+// SetLearner makes the sniffer pass every answered request with valid CRCs
+// to l, which learns the slave's registers under the slave address. It must
+// be called before Open.
+func (s *Sniffer) SetLearner(l *learn.Learner) {
+	s.learner = l
+}
+
+// Open creates the virtual serial ports and starts relaying.
+func (s *Sniffer) Open() error {
+	return s.pair.Open()
+}
+
+// Close removes the virtual serial ports.
+func (s *Sniffer) Close() error {
+	err := s.pair.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, a := range []*assembler{&s.requests, &s.responses} {
+		if a.timer != nil {
+			a.timer.Stop()
+		}
+	}
+	return err
+}
+
+// Status describes the virtual serial ports.
+func (s *Sniffer) Status() string {
+	return s.pair.Status()
+}
+
+// tap receives the bytes relayed between master and slave. Bytes from the
+// master are requests, bytes from the slave responses.
+func (s *Sniffer) tap(fromMaster bool, b []byte) {
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	a := &s.responses
+	if fromMaster {
+		a = &s.requests
+	}
+	if len(a.buf) == 0 {
+		a.started = now
+	}
+	a.buf = append(a.buf, b...)
+	a.last = now
+	a.chunks++
+
+	for {
+		n := frameLength(a.buf, a.request)
+		if n == 0 || len(a.buf) < n {
+			break
+		}
+		s.log(a.request, a.buf[:n], a.started, now)
+		a.buf = a.buf[n:]
+		a.started = now
+	}
+
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	if len(a.buf) > 0 {
+		chunks := a.chunks
+		a.timer = time.AfterFunc(frameTimeout, func() { s.flush(a, chunks) })
+	}
+}
+
+// flush logs the incomplete bytes collected by a as one frame once the line
+// has been silent for frameTimeout, i.e. no chunk arrived after the chunks-th.
+func (s *Sniffer) flush(a *assembler, chunks int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(a.buf) == 0 || a.chunks != chunks {
+		return
+	}
+	s.log(a.request, a.buf, a.started, a.last)
+	a.buf = nil
+}
+
+// log prints frame raw and decoded. started is the arrival of the first and
+// ended of the last byte of frame.
+func (s *Sniffer) log(request bool, frame []byte, started, ended time.Time) {
+	crc := "ok"
+	if !crcValid(frame) {
+		crc = "invalid"
+	}
+
+	if request {
+		s.protocolPort.Separator()
+		s.protocolPort.Info(fmt.Sprintf("M>S % X", frame))
+		s.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("%s CRC=%s", describeRequest(frame), crc)))
+		s.request = append([]byte(nil), frame...)
+		s.requestEnd = ended
+		return
+	}
+
+	latency := "n/a"
+	if s.request != nil {
+		latency = started.Sub(s.requestEnd).Round(time.Microsecond).String()
+	}
+	s.protocolPort.Info(fmt.Sprintf("S>M % X", frame))
+	s.protocolPort.InfoX(message.NewEncoded(fmt.Sprintf("%s CRC=%s Latency=%s", describeResponse(frame, s.request), crc, latency)))
+	if s.learner != nil && s.request != nil && crcValid(s.request) && crcValid(frame) && s.request[0] == frame[0] {
+		s.learner.Observe(s.slaveAddress, frame[0], pdu(s.request), pdu(frame))
+	}
+	s.request = nil
+}
@@ -3,43 +3,208 @@
 package socat
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs"
 )
 
-// StartAll starts a socat process for each RTU transport in cfg. It returns a
-// cleanup function that kills all started processes.
-func StartAll(transports []config.Transport) (func(), error) {
-	var procs []*os.Process
-	for _, t := range transports {
-		if t.Type != "rtu" {
-			continue
+// Restart delays of a crashed socat process. The delay doubles with every
+// restart of a process that crashed again shortly after it was started.
+const (
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = 10 * time.Second
+	stableRuntime   = 10 * time.Second
+)
+
+// linkTimeout is the time socat gets to create the link at serverTTY.
+const linkTimeout = time.Second
+
+// terminateTimeout is the time socat gets to remove its links after SIGTERM
+// before it is killed.
+const terminateTimeout = time.Second
+
+// Pair is a virtual serial port pair created by a socat process. It is the
+// fallback for platforms without native pseudo-terminal support. A socat
+// process that exits unexpectedly is reaped and restarted with backoff.
+type Pair struct {
+	serverTTY    string
+	peerTTY      string
+	protocolPort modbuslabs.ProtocolPort
+
+	lock     sync.Mutex
+	cmd      *exec.Cmd
+	restarts int
+	lastErr  error
+	closing  chan struct{} // closed by Close
+	stopped  chan struct{} // closed when the supervisor has terminated
+}
+
+// NewPair creates a pair linked at serverTTY (used by slavesim) and peerTTY
+// (used by the master). socat is started by Open, its diagnostic output is
+// written to protocolPort.
+func NewPair(serverTTY, peerTTY string, protocolPort modbuslabs.ProtocolPort) *Pair {
+	return &Pair{serverTTY: serverTTY, peerTTY: peerTTY, protocolPort: protocolPort}
+}
+
+// Open starts the socat process and its supervisor.
+func (p *Pair) Open() error {
+	cmd, err := start(p.serverTTY, p.peerTTY, p.stderr())
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.cmd = cmd
+	p.closing = make(chan struct{})
+	p.stopped = make(chan struct{})
+	p.lock.Unlock()
+
+	go p.supervise(cmd)
+	return nil
+}
+
+// Close stops the supervisor and terminates the socat process. socat is
+// killed if it doesn't terminate in time.
+func (p *Pair) Close() error {
+	p.lock.Lock()
+	if p.closing == nil {
+		p.lock.Unlock()
+		return nil
+	}
+	close(p.closing)
+	p.closing = nil
+	if p.cmd != nil {
+		if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			_ = p.cmd.Process.Kill()
+		}
+	}
+	p.lock.Unlock()
+
+	select {
+	case <-p.stopped:
+	case <-time.After(terminateTimeout):
+		// Without a process, the supervisor is restarting socat and kills
+		// the process it started once it sees the pair closing.
+		p.lock.Lock()
+		if p.cmd != nil {
+			_ = p.cmd.Process.Kill()
+		}
+		p.lock.Unlock()
+		<-p.stopped
+	}
+	return nil
+}
+
+// Status describes the health of the socat process backing the pair.
+func (p *Pair) Status() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	status := fmt.Sprintf("socat %s <-> %s: ", p.serverTTY, p.peerTTY)
+	switch {
+	case p.closing == nil:
+		status += "not running"
+	case p.cmd == nil:
+		status += fmt.Sprintf("restarting (%s)", p.lastErr)
+	default:
+		status += fmt.Sprintf("running (pid %d)", p.cmd.Process.Pid)
+	}
+	if p.restarts > 0 {
+		status += fmt.Sprintf(", %d restarts", p.restarts)
+	}
+	return status
+}
+
+// supervise waits for the socat process to exit, which also reaps it, and
+// restarts it until the pair is closed.
+func (p *Pair) supervise(cmd *exec.Cmd) {
+	defer close(p.stopped)
+
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		err := cmd.Wait()
+
+		p.lock.Lock()
+		closing := p.closing
+		p.cmd = nil
+		if err == nil {
+			err = fmt.Errorf("exited")
 		}
-		proc, err := start(t.Address, t.PeerAddress)
-		if err != nil {
-			for _, p := range procs {
-				_ = p.Kill()
+		p.lastErr = err
+		p.lock.Unlock()
+		if closing == nil {
+			return
+		}
+
+		if time.Since(started) > stableRuntime {
+			delay = minRestartDelay
+		}
+		p.protocolPort.Info(fmt.Sprintf("socat for %s terminated: %s, restarting in %s", p.serverTTY, err, delay))
+
+		for {
+			select {
+			case <-closing:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxRestartDelay)
+
+			cmd, err = start(p.serverTTY, p.peerTTY, p.stderr())
+			if err == nil {
+				break
 			}
-			return func() {}, err
+			p.lock.Lock()
+			p.lastErr = err
+			p.lock.Unlock()
+			p.protocolPort.Info(fmt.Sprintf("restarting socat for %s failed: %s, retrying in %s", p.serverTTY, err, delay))
 		}
-		procs = append(procs, proc)
+
+		p.lock.Lock()
+		p.cmd = cmd
+		p.restarts++
+		if p.closing == nil {
+			_ = cmd.Process.Kill()
+		}
+		p.lock.Unlock()
+		p.protocolPort.Info(fmt.Sprintf("socat for %s restarted (pid %d)", p.serverTTY, cmd.Process.Pid))
 	}
-	return func() {
-		for _, p := range procs {
-			_ = p.Kill()
+}
+
+func (p *Pair) stderr() io.Writer {
+	return &logWriter{protocolPort: p.protocolPort, prefix: fmt.Sprintf("socat %s: ", p.serverTTY)}
+}
+
+// logWriter forwards socat's diagnostic output line by line to the protocol
+// log.
+type logWriter struct {
+	protocolPort modbuslabs.ProtocolPort
+	prefix       string
+	buf          []byte
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(b), nil
 		}
-	}, nil
+		w.protocolPort.Info(w.prefix + string(bytes.TrimSpace(w.buf[:i])))
+		w.buf = w.buf[i+1:]
+	}
 }
 
 // start launches socat to create a virtual serial port pair. serverTTY is the
-// slave-side TTY; peerTTY is the client-side TTY. It waits 100ms and verifies
-// that serverTTY exists before returning.
-func start(serverTTY, peerTTY string) (*os.Process, error) {
+// slave-side TTY; peerTTY is the client-side TTY. It waits until serverTTY
+// exists, but at most linkTimeout.
+func start(serverTTY, peerTTY string, stderr io.Writer) (*exec.Cmd, error) {
 	path, err := exec.LookPath("socat")
 	if err != nil {
 		return nil, fmt.Errorf("socat not found: install socat first")
@@ -51,18 +216,20 @@ func start(serverTTY, peerTTY string) (*os.Process, error) {
 		fmt.Sprintf("pty,link=%s,raw,echo=0", peerTTY),
 	)
 	cmd.Stdout = io.Discard
-	cmd.Stderr = io.Discard
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start socat: %w", err)
 	}
 
-	time.Sleep(100 * time.Millisecond)
-
-	if _, err := os.Stat(serverTTY); os.IsNotExist(err) {
-		_ = cmd.Process.Kill()
-		return nil, fmt.Errorf("%s doesn't exist", serverTTY)
+	for deadline := time.Now().Add(linkTimeout); time.Now().Before(deadline); {
+		if _, err := os.Stat(serverTTY); err == nil {
+			return cmd, nil
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 
-	return cmd.Process, nil
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	return nil, fmt.Errorf("%s doesn't exist", serverTTY)
 }
@@ -0,0 +1,47 @@
+package tcp
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/rwirdemann/modbuslabs/pcap"
+)
+
+// ephemeralPort is the first client port used in captures of connections
+// without a TCP client address, e.g. on Unix domain sockets.
+const ephemeralPort = 49152
+
+// openCapture creates the capture file configured in Options.PcapFile.
+func (h *Handler) openCapture() error {
+	if h.options.PcapFile == "" {
+		return nil
+	}
+	w, err := pcap.Create(h.options.PcapFile, pcap.LinkTypeRaw)
+	if err != nil {
+		return err
+	}
+	h.capture = w
+	return nil
+}
+
+// captureStream starts the capture of the frames exchanged on c. The server
+// side always uses pcap.ModbusPort, so that Wireshark recognizes the traffic
+// as Modbus/TCP. Addresses that aren't IPv4 are replaced by the loopback
+// address.
+func (h *Handler) captureStream(c *Connection) *pcap.Stream {
+	n := h.streams.Add(1)
+	client := captureAddr(c.conn.RemoteAddr(), ephemeralPort+uint16(n%16384))
+	server := netip.AddrPortFrom(captureAddr(c.conn.LocalAddr(), 0).Addr(), pcap.ModbusPort)
+	return h.capture.OpenStream(client, server)
+}
+
+func captureAddr(addr net.Addr, fallbackPort uint16) netip.AddrPort {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		addrPort := tcpAddr.AddrPort()
+		if ip := addrPort.Addr().Unmap(); ip.Is4() {
+			return netip.AddrPortFrom(ip, addrPort.Port())
+		}
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), addrPort.Port())
+	}
+	return netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), fallbackPort)
+}
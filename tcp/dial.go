@@ -0,0 +1,92 @@
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+)
+
+// Connection modes of a Handler. In listen mode the handler accepts
+// connections from masters. In dial mode it connects to the master itself
+// and serves requests on the dialled connection, like a cellular RTU that
+// opens an outbound connection to its SCADA master.
+const (
+	ModeListen = "listen"
+	ModeDial   = "dial"
+)
+
+// Default reconnect delays used in dial mode when none are configured.
+const (
+	DefaultReconnectDelay    = time.Second
+	DefaultMaxReconnectDelay = 30 * time.Second
+)
+
+// dialTimeout limits a single connection attempt in dial mode.
+const dialTimeout = 5 * time.Second
+
+// startDialCycle connects to the master at h.url and serves its requests.
+// When the connection fails or is lost, it reconnects with exponential
+// backoff until ctx is cancelled or the handler is stopped.
+func (h *Handler) startDialCycle(ctx context.Context, processPDU modbuslabs.ProcessPDUCallback) {
+	defer h.wg.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-h.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	minDelay := h.options.ReconnectDelay
+	if minDelay <= 0 {
+		minDelay = DefaultReconnectDelay
+	}
+	maxDelay := h.options.MaxReconnectDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxReconnectDelay
+	}
+	delay := minDelay
+
+	for {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		conn, err := dialer.DialContext(ctx, h.network, h.url)
+		if err == nil {
+			if h.tlsConfig != nil {
+				conn = tls.Server(conn, h.tlsConfig)
+			}
+			h.protocolPort.Info(fmt.Sprintf("connected to master %s", h.url))
+			delay = minDelay
+			c := NewConnection(conn)
+			if h.register(c) {
+				h.serve(c, processPDU)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			h.protocolPort.Info(fmt.Sprintf("connection to master %s lost, reconnecting in %s", h.url, delay))
+		} else {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Debug("dial failed", "url", h.url, "err", err)
+			h.protocolPort.Info(fmt.Sprintf("connecting to master %s failed, retrying in %s", h.url, delay))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err != nil {
+			delay = min(delay*2, maxDelay)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package tcp
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/fault"
 	"github.com/rwirdemann/modbuslabs/message"
+	"github.com/rwirdemann/modbuslabs/netem"
+	"github.com/rwirdemann/modbuslabs/pcap"
 )
 
 const (
@@ -19,46 +28,122 @@ const (
 	MaxFrameLength   = 260
 )
 
+// Overflow strategies applied when a new client connects while the handler
+// already serves the maximum number of connections.
+const (
+	OverflowReject      = "reject"
+	OverflowEvictOldest = "evict_oldest"
+)
+
+// DefaultShutdownTimeout is the time Stop waits for in-flight requests when
+// no shutdown timeout is configured.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// Options configures the connection management of a Handler. The zero value
+// accepts any number of connections and never times out idle clients.
+type Options struct {
+	MaxConnections    int             // maximum number of concurrent connections, 0 = unlimited
+	Overflow          string          // OverflowReject (default) or OverflowEvictOldest
+	ReadTimeout       time.Duration   // maximum time to receive the rest of a started frame, 0 = unlimited
+	IdleTimeout       time.Duration   // close connections without a request for this long, 0 = never
+	ShutdownTimeout   time.Duration   // maximum time Stop waits for in-flight requests
+	Mode              string          // ModeListen (default) or ModeDial
+	ReconnectDelay    time.Duration   // dial mode only: initial delay between connection attempts, doubled after each failure
+	MaxReconnectDelay time.Duration   // dial mode only: upper bound of the reconnect delay
+	Pipelining        int             // maximum number of concurrently processed requests per connection, 0 or 1 = sequential
+	ReorderWindow     time.Duration   // pipelining only: hold responses for this long and send them in reverse order
+	PcapFile          string          // record all frames to this PCAP file, "" = no capture
+	Faults            *fault.Injector // faults injected into responses, nil = none
+	Network           *netem.Emulator // network conditions of responses, nil = perfect link
+}
+
+// Connection is a client connection served by a Handler.
 type Connection struct {
-	conn net.Conn
+	conn     net.Conn
+	reader   *bufio.Reader
+	role     string
+	opened   time.Time
+	requests atomic.Int64
+	capture  *pcap.Stream // nil without capture
+	halfOpen atomic.Bool  // set by the network emulation, no more bytes are written
 }
 
 func NewConnection(c net.Conn) *Connection {
-	return &Connection{conn: c}
+	return &Connection{conn: c, reader: bufio.NewReader(c), opened: time.Now()}
 }
 
-func (r Connection) Read(p []byte) (n int, err error) {
-	return r.conn.Read(p)
+func (r *Connection) Read(p []byte) (n int, err error) {
+	return r.reader.Read(p)
 }
 
-func (r Connection) Write(b []byte) (n int, err error) {
+func (r *Connection) Write(b []byte) (n int, err error) {
 	return r.conn.Write(b)
 }
 
-func (r Connection) Close() {
+func (r *Connection) Close() {
 	r.conn.Close()
 }
 
-func (r Connection) Name() string {
-	return r.conn.RemoteAddr().String()
+func (r *Connection) Name() string {
+	// Clients of Unix domain sockets are usually unnamed.
+	if name := r.conn.RemoteAddr().String(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s client %s", r.conn.LocalAddr().Network(), r.opened.Format(time.StampMilli))
 }
 
 type Handler struct {
 	url          string
+	network      string // "tcp" or "unix"
+	options      Options
 	listener     net.Listener
+	tlsConfig    *tls.Config
 	protocolPort modbuslabs.ProtocolPort
+
+	connLock    sync.Mutex
+	connections []*Connection // ordered by connect time, oldest first
+	stopping    bool
+	stopped     chan struct{}  // closed by Stop
+	wg          sync.WaitGroup // one per running connection goroutine and dial cycle
+
+	capture *pcap.Writer // nil without capture
+	streams atomic.Uint32
 }
 
-func NewHandler(url string, protocolPort modbuslabs.ProtocolPort) (*Handler, error) {
+func NewHandler(url string, options Options, protocolPort modbuslabs.ProtocolPort) (*Handler, error) {
 	splitURL := strings.SplitN(url, "://", 2)
 	if len(splitURL) == 2 {
-		return &Handler{url: splitURL[1], protocolPort: protocolPort}, nil
+		network := "tcp"
+		if splitURL[0] == "unix" {
+			network = "unix"
+		}
+		return &Handler{url: splitURL[1], network: network, options: options, protocolPort: protocolPort, stopped: make(chan struct{})}, nil
 	}
 	return nil, fmt.Errorf("invalid url format %s", url)
 }
 
 func (h *Handler) Start(ctx context.Context, processPDU modbuslabs.ProcessPDUCallback) (err error) {
-	h.listener, err = net.Listen("tcp", h.url)
+	if err := h.openCapture(); err != nil {
+		return err
+	}
+
+	if h.options.Mode == ModeDial {
+		h.wg.Add(1)
+		go h.startDialCycle(ctx, processPDU)
+		slog.Debug("TCP dialer started", "url", h.url)
+		return nil
+	}
+
+	if h.network == "unix" {
+		if err := removeStaleSocket(h.url); err != nil {
+			return fmt.Errorf("failed to start Unix listener: %w", err)
+		}
+	}
+	if h.tlsConfig != nil {
+		h.listener, err = tls.Listen(h.network, h.url, h.tlsConfig)
+	} else {
+		h.listener, err = net.Listen(h.network, h.url)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to start TCP listener: %w", err)
 	}
@@ -67,18 +152,103 @@ func (h *Handler) Start(ctx context.Context, processPDU modbuslabs.ProcessPDUCal
 	return nil
 }
 
+// Stop closes the listener and shuts down all client connections gracefully:
+// requests that are already being processed are answered, then every
+// connection is closed. Connections that are still busy when the shutdown
+// timeout expires are closed forcibly.
 func (h *Handler) Stop() error {
+	var err error
 	if h.listener != nil {
 		slog.Debug("Stopping TCP listener", "url", h.url)
-		return h.listener.Close()
+		err = h.listener.Close()
 	}
-	return nil
+
+	// Unblock all pending reads. Connections waiting for a request terminate
+	// immediately, busy ones after their response has been written.
+	h.connLock.Lock()
+	if h.stopping {
+		h.connLock.Unlock()
+		return nil
+	}
+	h.stopping = true
+	close(h.stopped)
+	for _, c := range h.connections {
+		_ = c.conn.SetReadDeadline(time.Now())
+	}
+	h.connLock.Unlock()
+
+	timeout := h.options.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Debug("shutdown timeout expired, closing remaining connections", "url", h.url)
+		h.connLock.Lock()
+		for _, c := range h.connections {
+			c.Close()
+		}
+		h.connLock.Unlock()
+		<-done
+	}
+
+	if h.capture != nil {
+		_ = h.capture.Close()
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
 }
 
 func (h *Handler) Description() string {
 	return h.url
 }
 
+// Faults returns the fault injector of the handler, nil if no faults are
+// configured.
+func (h *Handler) Faults() *fault.Injector {
+	return h.options.Faults
+}
+
+// Status lists the open client connections, the injected faults and the
+// emulated network conditions.
+func (h *Handler) Status() string {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	limit := "unlimited"
+	if h.options.MaxConnections > 0 {
+		limit = fmt.Sprintf("%d", h.options.MaxConnections)
+	}
+	status := fmt.Sprintf("\n  Connections: %d/%s", len(h.connections), limit)
+	if h.options.Mode == ModeDial {
+		status = "\n  Mode: dial" + status
+	}
+	for _, c := range h.connections {
+		status += fmt.Sprintf("\n  - %s since %s, %d requests", c.Name(), c.opened.Format(time.TimeOnly), c.requests.Load())
+		if c.role != "" {
+			status += fmt.Sprintf(", role %q", c.role)
+		}
+		if c.halfOpen.Load() {
+			status += ", half-open"
+		}
+	}
+	if h.options.Faults != nil {
+		status += h.options.Faults.Status()
+	}
+	if h.options.Network != nil {
+		status += h.options.Network.Status()
+	}
+	return status
+}
+
 func (h *Handler) startRequestCycle(ctx context.Context, processPDU modbuslabs.ProcessPDUCallback) {
 	for {
 		select {
@@ -87,45 +257,233 @@ func (h *Handler) startRequestCycle(ctx context.Context, processPDU modbuslabs.P
 		default:
 			slog.Debug("listening...")
 			conn, err := h.listener.Accept()
-			if err == nil {
-				go func() {
-					for {
-						if err := h.processRequest(conn, processPDU); err != nil {
-							break
-						}
-					}
-				}()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				slog.Debug("accept failed", "err", err)
+				continue
 			}
+			c := NewConnection(conn)
+			if !h.register(c) {
+				continue
+			}
+			go h.serve(c, processPDU)
 		}
 	}
 }
 
-func (h *Handler) processRequest(conn net.Conn, processPDU modbuslabs.ProcessPDUCallback) error {
-	header, pdu, txnId, err := readMBAPFrame(conn)
+// register adds c to the list of open connections. If the connection limit
+// is reached, either c is rejected or the oldest connection is evicted,
+// depending on the configured overflow strategy.
+func (h *Handler) register(c *Connection) bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	if h.stopping {
+		c.Close()
+		return false
+	}
+
+	if h.options.MaxConnections > 0 && len(h.connections) >= h.options.MaxConnections {
+		if h.options.Overflow != OverflowEvictOldest {
+			h.protocolPort.Info(fmt.Sprintf("connection from %s rejected: limit of %d connections reached", c.Name(), h.options.MaxConnections))
+			c.Close()
+			return false
+		}
+		oldest := h.connections[0]
+		h.protocolPort.Info(fmt.Sprintf("connection from %s evicted in favour of %s", oldest.Name(), c.Name()))
+		oldest.Close()
+		h.connections = h.connections[1:]
+	}
+
+	h.connections = append(h.connections, c)
+	h.wg.Add(1)
+	return true
+}
+
+func (h *Handler) unregister(c *Connection) {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+	h.connections = slices.DeleteFunc(h.connections, func(o *Connection) bool { return o == c })
+}
+
+// serve processes requests on c until the client disconnects, the connection
+// times out or the handler is stopped.
+func (h *Handler) serve(c *Connection, processPDU modbuslabs.ProcessPDUCallback) {
+	defer h.wg.Done()
+	defer h.unregister(c)
+	defer c.Close()
+
+	if err := c.conn.SetDeadline(deadline(h.options.IdleTimeout)); err != nil {
+		return
+	}
+	role, err := peerRole(c.conn)
 	if err != nil {
-		if err == io.EOF {
-			slog.Debug("client disconnected", "remote addr", conn.RemoteAddr())
-			conn.Close()
+		h.protocolPort.Info(fmt.Sprintf("connection from %s rejected: %s", c.Name(), err))
+		return
+	}
+	if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
+		return
+	}
+	c.role = role
+
+	if h.capture != nil {
+		c.capture = h.captureStream(c)
+		defer c.capture.Close()
+	}
+
+	if h.options.Pipelining > 1 {
+		h.servePipelined(c, processPDU)
+		return
+	}
+
+	for {
+		if err := h.processRequest(c, processPDU); err != nil {
+			h.logClose(c, err)
+			return
 		}
+	}
+}
+
+// logClose reports connections that are closed because of a read or idle
+// timeout. Disconnects and shutdown are not reported.
+func (h *Handler) logClose(c *Connection, err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && !h.isStopping() {
+		h.protocolPort.Info(fmt.Sprintf("connection from %s closed: timeout", c.Name()))
+	}
+}
+
+func (h *Handler) isStopping() bool {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+	return h.stopping
+}
+
+// awaitRequest blocks until the first byte of the next request arrives on c,
+// applying the idle timeout. Afterwards the read timeout covers the rest of
+// the frame.
+func (h *Handler) awaitRequest(c *Connection) error {
+	h.connLock.Lock()
+	if h.stopping {
+		h.connLock.Unlock()
+		return net.ErrClosed
+	}
+	err := c.conn.SetReadDeadline(deadline(h.options.IdleTimeout))
+	h.connLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.reader.Peek(1); err != nil {
 		return err
 	}
+	return c.conn.SetReadDeadline(deadline(h.options.ReadTimeout))
+}
+
+// deadline returns the point in time d from now or the zero time, meaning no
+// deadline, if d is not positive.
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+func (h *Handler) processRequest(c *Connection, processPDU modbuslabs.ProcessPDUCallback) error {
+	pdu, txnId, err := h.readRequest(c)
+	if err != nil {
+		return err
+	}
+
+	res := processPDU(*pdu)
+
+	if res != nil {
+		if err := h.writeResponse(c, txnId, res, h.drawFaults(res)); err != nil {
+			return err
+		}
+	}
+	h.protocolPort.Separator()
+	return nil
+}
+
+// readRequest waits for the next request on c and returns its PDU together
+// with the transaction id.
+func (h *Handler) readRequest(c *Connection) (*modbuslabs.PDU, uint16, error) {
+	if err := h.awaitRequest(c); err != nil {
+		if err == io.EOF {
+			slog.Debug("client disconnected", "remote addr", c.Name())
+		}
+		return nil, 0, err
+	}
+
+	header, pdu, txnId, err := readMBAPFrame(c)
+	if err != nil {
+		if err == io.EOF {
+			slog.Debug("client disconnected", "remote addr", c.Name())
+		}
+		return nil, 0, err
+	}
+	c.requests.Add(1)
+	pdu.Role = c.role
+	if c.capture != nil {
+		c.capture.Request(append(append(header, pdu.FunctionCode), pdu.Payload...))
+	}
 	slog.Debug("MBAP header received", "pdu", pdu, "txid", txnId)
 
 	h.protocolPort.Separator()
 	m := message.Unencoded{Value: fmt.Sprintf("TX % X %02X % X", header, pdu.FunctionCode, pdu.Payload)}
 	h.protocolPort.InfoX(m)
+	return pdu, txnId, nil
+}
 
-	res := processPDU(*pdu)
+// drawFaults draws the faults injected into res and waits for the drawn
+// delay. Pipelined responses are delayed before they are queued, so that a
+// delayed response doesn't hold back the others.
+func (h *Handler) drawFaults(res *modbuslabs.PDU) fault.Plan {
+	plan := h.options.Faults.Draw(res.UnitId)
+	if !plan.Empty() {
+		h.protocolPort.Info(fmt.Sprintf("FAULT %s for unit %d", plan, res.UnitId))
+	}
+	if !plan.Drop {
+		time.Sleep(plan.Delay)
+	}
+	return plan
+}
 
-	if res != nil {
-		payload := modbuslabs.AssembleMBAPFrame(txnId, res)
-		if _, err := conn.Write(payload); err != nil {
+// writeResponse writes res as MBAP frame tagged with txnId to c, modified by
+// the faults of plan.
+func (h *Handler) writeResponse(c *Connection, txnId uint16, res *modbuslabs.PDU, plan fault.Plan) error {
+	if plan.Drop {
+		return nil
+	}
+	if plan.WrongTransactionID {
+		txnId++
+	}
+	payload := modbuslabs.AssembleMBAPFrame(txnId, res)
+	if plan.FlipBits {
+		fault.FlipBit(payload[MBAPHeaderLength+1:])
+	}
+	if plan.Truncate {
+		payload = fault.TruncateFrame(payload)
+	}
+
+	writes := 1
+	if plan.Duplicate {
+		writes = 2
+	}
+	for range writes {
+		sent, err := h.write(c, payload)
+		if err != nil || !sent {
 			return err
 		}
-		slog.Debug(fmt.Sprintf("MBAP response written: % X", payload))
-		h.protocolPort.InfoX(message.NewUnencoded(fmt.Sprintf("RX % X", payload)))
+		if c.capture != nil {
+			c.capture.Response(payload)
+		}
 	}
-	h.protocolPort.Separator()
+	slog.Debug(fmt.Sprintf("MBAP response written: % X", payload))
+	h.protocolPort.InfoX(message.NewUnencoded(fmt.Sprintf("RX % X", payload)))
 	return nil
 }
 
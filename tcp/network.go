@@ -0,0 +1,58 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/rwirdemann/modbuslabs/netem"
+)
+
+// errReset is returned when a connection has been reset by the network
+// emulation.
+var errReset = errors.New("connection reset by network emulation")
+
+// Network returns the network emulator of the handler, nil if no network
+// conditions are configured.
+func (h *Handler) Network() *netem.Emulator {
+	return h.options.Network
+}
+
+// write writes b to c under the emulated network conditions and reports
+// whether b has been sent. Nothing is sent on half-open connections.
+func (h *Handler) write(c *Connection, b []byte) (bool, error) {
+	if c.halfOpen.Load() {
+		return false, nil
+	}
+	e := h.options.Network
+	if e.HalfOpen() {
+		c.halfOpen.Store(true)
+		h.protocolPort.Info(fmt.Sprintf("NET connection from %s is half-open now, no more responses are sent", c.Name()))
+		return false, nil
+	}
+	if n, reset := e.Reset(len(b)); reset {
+		if n > 0 {
+			_, _ = e.Write(c, b[:n])
+		}
+		abort(c.conn)
+		h.protocolPort.Info(fmt.Sprintf("NET connection from %s reset after %d of %d response bytes", c.Name(), n, len(b)))
+		return false, errReset
+	}
+	if _, err := e.Write(c, b); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// abort closes conn with a TCP reset instead of the regular shutdown. Unix
+// domain sockets are just closed.
+func abort(conn net.Conn) {
+	if t, ok := conn.(*tls.Conn); ok {
+		conn = t.NetConn()
+	}
+	if t, ok := conn.(*net.TCPConn); ok {
+		_ = t.SetLinger(0)
+	}
+	_ = conn.Close()
+}
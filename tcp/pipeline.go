@@ -0,0 +1,97 @@
+package tcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/fault"
+)
+
+// response is a processed request waiting to be written back to the client.
+type response struct {
+	txnId uint16
+	pdu   *modbuslabs.PDU
+	plan  fault.Plan
+}
+
+// servePipelined processes up to Options.Pipelining requests of c
+// concurrently. Every response carries the transaction id of its request, so
+// responses may reach the client in a different order than the requests.
+func (h *Handler) servePipelined(c *Connection, processPDU modbuslabs.ProcessPDUCallback) {
+	slots := make(chan struct{}, h.options.Pipelining)
+	responses := make(chan response)
+	written := make(chan struct{})
+	go h.writeResponses(c, responses, written)
+
+	var inFlight sync.WaitGroup
+	for {
+		pdu, txnId, err := h.readRequest(c)
+		if err != nil {
+			h.logClose(c, err)
+			break
+		}
+
+		slots <- struct{}{}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			defer func() { <-slots }()
+			if res := processPDU(*pdu); res != nil {
+				responses <- response{txnId: txnId, pdu: res, plan: h.drawFaults(res)}
+			}
+		}()
+	}
+
+	inFlight.Wait()
+	close(responses)
+	<-written
+}
+
+// writeResponses writes the responses of a pipelined connection. With a
+// reorder window, responses are held back for the window's duration and the
+// collected responses are written in reverse order. This lets masters prove
+// that they match responses by transaction id rather than by order.
+func (h *Handler) writeResponses(c *Connection, responses <-chan response, written chan<- struct{}) {
+	defer close(written)
+
+	var held []response
+	var flushTimer <-chan time.Time
+	write := func(r response) {
+		if err := h.writeResponse(c, r.txnId, r.pdu, r.plan); err != nil {
+			h.protocolPort.Info(fmt.Sprintf("response for transaction %d to %s failed: %s", r.txnId, c.Name(), err))
+		}
+		h.protocolPort.Separator()
+	}
+	flush := func() {
+		if len(held) > 1 {
+			h.protocolPort.Info(fmt.Sprintf("sending %d responses to %s in reverse order", len(held), c.Name()))
+		}
+		for i := len(held) - 1; i >= 0; i-- {
+			write(held[i])
+		}
+		held = nil
+		flushTimer = nil
+	}
+
+	for {
+		select {
+		case r, ok := <-responses:
+			if !ok {
+				flush()
+				return
+			}
+			if h.options.ReorderWindow <= 0 {
+				write(r)
+				continue
+			}
+			held = append(held, r)
+			if flushTimer == nil {
+				flushTimer = time.After(h.options.ReorderWindow)
+			}
+		case <-flushTimer:
+			flush()
+		}
+	}
+}
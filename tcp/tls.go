@@ -0,0 +1,92 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/rwirdemann/modbuslabs"
+)
+
+// RoleOID identifies the X.509 v3 extension that carries the client's role
+// as defined by the Modbus/TCP Security specification. The extension value
+// is an ASN.1 UTF8String.
+var RoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// NewTLSConfig creates a server TLS configuration for Modbus/TCP Security.
+// Clients must present a certificate signed by one of the CAs in caFile
+// (mutual TLS) and TLS 1.2 is the minimum accepted version.
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no CA certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// NewTLSHandler creates a handler serving Modbus/TCP Security on url. The
+// role found in the client certificate is attached to every PDU received on
+// the connection.
+func NewTLSHandler(url string, options Options, tlsConfig *tls.Config, protocolPort modbuslabs.ProtocolPort) (*Handler, error) {
+	h, err := NewHandler(url, options, protocolPort)
+	if err != nil {
+		return nil, err
+	}
+	h.tlsConfig = tlsConfig
+	return h, nil
+}
+
+// peerRole completes the TLS handshake on conn and returns the role from the
+// client's leaf certificate. It returns an empty role for plain connections
+// and for certificates without a role extension.
+func peerRole(conn net.Conn) (string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return "", fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return "", errors.New("client presented no certificate")
+	}
+	return RoleFromCertificate(state.PeerCertificates[0])
+}
+
+// RoleFromCertificate extracts the Modbus/TCP Security role from cert.
+func RoleFromCertificate(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(RoleOID) {
+			continue
+		}
+		var role string
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &role, "utf8"); err != nil {
+			return "", fmt.Errorf("invalid role extension: %w", err)
+		}
+		return role, nil
+	}
+	slog.Debug("client certificate has no role extension", "subject", cert.Subject)
+	return "", nil
+}
@@ -0,0 +1,257 @@
+package tcp_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rwirdemann/modbuslabs"
+	"github.com/rwirdemann/modbuslabs/config"
+	"github.com/rwirdemann/modbuslabs/message"
+	"github.com/rwirdemann/modbuslabs/tcp"
+)
+
+type nopProtocolPort struct{}
+
+func (nopProtocolPort) InfoX(message.Message) {}
+func (nopProtocolPort) Info(string)           {}
+func (nopProtocolPort) Println(string)        {}
+func (nopProtocolPort) Separator()            {}
+func (nopProtocolPort) ForceSeparator()       {}
+func (nopProtocolPort) Mute()                 {}
+func (nopProtocolPort) Unmute()               {}
+func (nopProtocolPort) Toggle()               {}
+
+// issuer creates certificates signed by a CA generated on the fly.
+type issuer struct {
+	t      *testing.T
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	ca := &issuer{t: t}
+	ca.cert, ca.key = ca.issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return ca
+}
+
+// issue signs template by the CA, or self-signs it if the CA has no
+// certificate yet.
+func (ca *issuer) issue(template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	ca.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	ca.serial++
+	template.SerialNumber = big.NewInt(ca.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	return cert, key
+}
+
+// client issues a client certificate with role, without role extension if
+// role is empty.
+func (ca *issuer) client(role string) tls.Certificate {
+	ca.t.Helper()
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if role != "" {
+		value, err := asn1.MarshalWithParams(role, "utf8")
+		if err != nil {
+			ca.t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: tcp.RoleOID, Value: value}}
+	}
+	cert, key := ca.issue(template)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+}
+
+// writePEM writes the CA certificate and a server certificate to dir and
+// returns the paths of the certificate, key and CA files.
+func (ca *issuer) writePEM(dir string) (string, string, string) {
+	ca.t.Helper()
+	cert, key := ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "slavesim"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	files := []struct {
+		name, kind string
+		der        []byte
+	}{
+		{"server.pem", "CERTIFICATE", cert.Raw},
+		{"server.key", "EC PRIVATE KEY", keyDER},
+		{"ca.pem", "CERTIFICATE", ca.cert.Raw},
+	}
+	for _, f := range files {
+		data := pem.EncodeToMemory(&pem.Block{Type: f.kind, Bytes: f.der})
+		if err := os.WriteFile(filepath.Join(dir, f.name), data, 0o600); err != nil {
+			ca.t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+}
+
+// startTLSGateway starts a gateway serving slave 1 on a TLS transport. Only
+// clients with the role "engineer" may write register 0x0010.
+func startTLSGateway(t *testing.T, ca *issuer) string {
+	t.Helper()
+	certFile, keyFile, caFile := ca.writePEM(t.TempDir())
+	tlsConfig, err := tcp.NewTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+
+	h, err := tcp.NewTLSHandler("tls://"+address, tcp.Options{}, tlsConfig, nopProtocolPort{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := modbuslabs.NewGateway([]modbuslabs.TransportHandler{h}, nopProtocolPort{})
+	gateway.ConnectSlaveWithConfig(config.Slave{
+		ID:      1,
+		Address: address,
+		Rules: []config.Rule{
+			{Trigger: "on_write", Register: 0x0010, Action: "require_role", Roles: []string{"engineer"}},
+		},
+	}, address)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := gateway.Start(ctx); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = h.Stop()
+	})
+	return address
+}
+
+// Requests writing register 0x0010 of slave 1
+var (
+	writeSingleRegister = []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x06, 0x00, 0x10, 0x00, 0x2A}
+	writeMultipleCoils  = []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x08, 0x01, 0x0F, 0x00, 0x10, 0x00, 0x02, 0x01, 0x03}
+)
+
+// send sends request to the gateway and returns the response PDU.
+func send(address string, config *tls.Config, request []byte) ([]byte, error) {
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	pdu := make([]byte, int(header[4])<<8|int(header[5])-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return nil, err
+	}
+	return pdu, nil
+}
+
+func TestTLSRoles(t *testing.T) {
+	ca := newIssuer(t)
+	address := startTLSGateway(t, ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name    string
+		role    string
+		request []byte
+		want    []byte
+	}{
+		{"authorized role", "engineer", writeSingleRegister, []byte{0x06, 0x00, 0x10, 0x00, 0x2A}},
+		{"other role", "operator", writeSingleRegister, []byte{0x86, modbuslabs.ExIllegalFunction}},
+		{"no role", "", writeSingleRegister, []byte{0x86, modbuslabs.ExIllegalFunction}},
+		{"authorized role FC15", "engineer", writeMultipleCoils, []byte{0x0F, 0x00, 0x10, 0x00, 0x02}},
+		{"other role FC15", "operator", writeMultipleCoils, []byte{0x8F, modbuslabs.ExIllegalFunction}},
+		{"no role FC15", "", writeMultipleCoils, []byte{0x8F, modbuslabs.ExIllegalFunction}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdu, err := send(address, &tls.Config{
+				RootCAs:      roots,
+				Certificates: []tls.Certificate{ca.client(tt.role)},
+			}, tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(pdu) != string(tt.want) {
+				t.Errorf("response = % X, want % X", pdu, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSRequiresClientCertificate(t *testing.T) {
+	ca := newIssuer(t)
+	address := startTLSGateway(t, ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	if _, err := send(address, &tls.Config{RootCAs: roots}, writeSingleRegister); err == nil {
+		t.Error("request without client certificate was answered")
+	}
+
+	other := newIssuer(t)
+	if _, err := send(address, &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{other.client("engineer")},
+	}, writeSingleRegister); err == nil {
+		t.Error("request with certificate of an unknown CA was answered")
+	}
+}
@@ -0,0 +1,34 @@
+package tcp
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
+// removeStaleSocket deletes the socket file at path if no process listens on
+// it anymore, e.g. after slavesim was killed. A socket that still accepts
+// connections is reported as error and left untouched.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use", path)
+	}
+
+	slog.Debug("removing stale socket", "path", path)
+	return os.Remove(path)
+}
@@ -2,6 +2,9 @@ package modbuslabs
 
 import (
 	"context"
+
+	"github.com/rwirdemann/modbuslabs/fault"
+	"github.com/rwirdemann/modbuslabs/netem"
 )
 
 type ProcessPDUCallback func(pdu PDU) *PDU
@@ -11,3 +14,21 @@ type TransportHandler interface {
 	Stop() error
 	Description() string
 }
+
+// StatusReporter is implemented by transport handlers that contribute
+// runtime details, such as open client connections, to the gateway status.
+type StatusReporter interface {
+	Status() string
+}
+
+// FaultInjector is implemented by transport handlers that inject faults into
+// their responses. Faults returns nil if no faults are configured.
+type FaultInjector interface {
+	Faults() *fault.Injector
+}
+
+// NetworkEmulator is implemented by transport handlers that emulate bad
+// network links. Network returns nil if no network conditions are configured.
+type NetworkEmulator interface {
+	Network() *netem.Emulator
+}
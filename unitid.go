@@ -0,0 +1,67 @@
+package modbuslabs
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Unit ID routing of a transport, see Gateway.SetUnitIDRouting. Strict routing passes the unit ID on as
+// received. Many single-device Modbus TCP servers ignore the unit ID, or
+// expect 0 or 255, and masters rely on that: with UnitIDRoutingAny every
+// request, with UnitIDRouting0And255 every request to unit 0 or 255, is
+// answered by the designated slave.
+const (
+	UnitIDRoutingStrict  = "strict"
+	UnitIDRoutingAny     = "any"
+	UnitIDRouting0And255 = "0_255"
+)
+
+// unitIDRouting is the unit ID routing of a transport.
+type unitIDRouting struct {
+	mode       string
+	designated uint8
+}
+
+// SetUnitIDRouting makes the designated slave answer the requests of the
+// transport at url according to mode, UnitIDRoutingAny or
+// UnitIDRouting0And255. It must be called before the gateway is started.
+func (m *Gateway) SetUnitIDRouting(url string, mode string, designated uint8) {
+	if mode == UnitIDRoutingAny || mode == UnitIDRouting0And255 {
+		m.unitIDRoutings[url] = unitIDRouting{mode: mode, designated: designated}
+	}
+}
+
+// routeUnitID wraps processPDU of the transport at url, so that requests
+// are answered by the designated slave according to the unit ID routing.
+// Responses carry the unit ID of the request. Recordings see the unit ID the
+// master sent, as they wrap the routed processPDU.
+func (m *Gateway) routeUnitID(url string, processPDU ProcessPDUCallback) ProcessPDUCallback {
+	routing, ok := m.unitIDRoutings[url]
+	if !ok {
+		return processPDU
+	}
+	return func(pdu PDU) *PDU {
+		unitID := pdu.UnitId
+		if routing.mode == UnitIDRoutingAny || unitID == 0 || unitID == 255 {
+			pdu.UnitId = routing.designated
+			slog.Debug("unit ID routed to designated slave", "unitID", unitID, "slave", routing.designated)
+		}
+		res := processPDU(pdu)
+		if res != nil {
+			res.UnitId = unitID
+		}
+		return res
+	}
+}
+
+// unitIDStatus describes the unit ID routing of the transport at url.
+func (m *Gateway) unitIDStatus(url string) string {
+	routing, ok := m.unitIDRoutings[url]
+	if !ok {
+		return ""
+	}
+	if routing.mode == UnitIDRoutingAny {
+		return fmt.Sprintf("\n  Unit IDs: any answered by slave %d", routing.designated)
+	}
+	return fmt.Sprintf("\n  Unit IDs: 0 and 255 answered by slave %d", routing.designated)
+}
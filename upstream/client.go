@@ -0,0 +1,117 @@
+// Package upstream forwards Modbus requests to a remote slave, e.g. a real
+// device behind a proxy slave, over Modbus TCP or RTU.
+package upstream
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/rwirdemann/modbuslabs"
+)
+
+// DefaultTimeout is the time the remote slave gets to answer a request.
+const DefaultTimeout = time.Second
+
+// Options configures a Client. Zero values select DefaultTimeout and 9600
+// baud, 8 data bits, no parity and 1 stop bit for RTU targets.
+type Options struct {
+	Timeout  time.Duration
+	BaudRate int
+	DataBits int
+	Parity   string // "N", "E" or "O"
+	StopBits int
+}
+
+type transporter interface {
+	modbus.Transporter
+	Connect() error
+	Close() error
+}
+
+// Client is a Modbus master connected to one remote target. Requests are
+// sent one at a time, so a client may be shared by several slaves on the
+// same serial bus. RTU targets must not echo requests, the echo would be
+// read as the response.
+type Client struct {
+	url         string
+	lock        sync.Mutex
+	packager    modbus.Packager
+	transporter transporter
+	slaveID     *byte // unit ID the packager addresses the next request to
+}
+
+// NewClient creates a client for url, which is either "tcp://host:port" or
+// "rtu://<serial device>". The connection is established on the first
+// request and reestablished after failures.
+func NewClient(url string, options Options) (*Client, error) {
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+
+	c := &Client{url: url}
+	if address, ok := strings.CutPrefix(url, "tcp://"); ok {
+		h := modbus.NewTCPClientHandler(address)
+		h.Timeout = options.Timeout
+		c.packager, c.transporter, c.slaveID = h, h, &h.SlaveId
+		return c, nil
+	}
+	if address, ok := strings.CutPrefix(url, "rtu://"); ok {
+		h := modbus.NewRTUClientHandler(address)
+		h.Timeout = options.Timeout
+		h.BaudRate = cmp.Or(options.BaudRate, 9600)
+		h.DataBits = cmp.Or(options.DataBits, 8)
+		h.Parity = cmp.Or(options.Parity, "N")
+		h.StopBits = cmp.Or(options.StopBits, 1)
+		c.packager, c.transporter, c.slaveID = h, h, &h.SlaveId
+		return c, nil
+	}
+	return nil, fmt.Errorf("unsupported upstream %q, must start with tcp:// or rtu://", url)
+}
+
+// Description returns the URL of the remote target.
+func (c *Client) Description() string {
+	return c.url
+}
+
+// Send forwards pdu to the remote target and returns its response, which may
+// be an exception response.
+func (c *Client) Send(pdu modbuslabs.PDU) (*modbuslabs.PDU, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.transporter.Connect(); err != nil {
+		return nil, fmt.Errorf("%w: %w", modbuslabs.ErrUpstreamUnavailable, err)
+	}
+	*c.slaveID = pdu.UnitId
+	request, err := c.packager.Encode(&modbus.ProtocolDataUnit{FunctionCode: pdu.FunctionCode, Data: pdu.Payload})
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.transporter.Send(request)
+	if err == nil {
+		err = c.packager.Verify(request, response)
+	}
+	if err != nil {
+		// Drop the connection, so a late or partial response can't be
+		// taken for the response of the next request.
+		_ = c.transporter.Close()
+		return nil, err
+	}
+	res, err := c.packager.Decode(response)
+	if err != nil {
+		_ = c.transporter.Close()
+		return nil, err
+	}
+	return &modbuslabs.PDU{UnitId: pdu.UnitId, FunctionCode: res.FunctionCode, Payload: res.Data}, nil
+}
+
+// Close closes the connection to the remote target.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.transporter.Close()
+}